@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdp/qrterminal/v3"
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/crossclipboard"
+)
+
+// defaultInviteTTL the default valid time of an invite token
+const defaultInviteTTL = 10 * time.Minute
+
+// command a command which can be entered in the terminal while running
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(cc *crossclipboard.CrossClipboard, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{
+			name:        "connect",
+			usage:       "connect <multiaddr>",
+			description: "connect to a peer by full multiaddr, e.g. /ip4/10.1.2.3/tcp/4001/p2p/<id>",
+			run:         connectCommand,
+		},
+		{
+			name:        "invite",
+			usage:       "invite [minutes]",
+			description: "issue a single-use invite token for a new device, valid for 10 minutes by default",
+			run:         inviteCommand,
+		},
+		{
+			name:        "join",
+			usage:       "join <token>",
+			description: "join the group by an invite token issued by a group member",
+			run:         joinCommand,
+		},
+		{
+			name:        "devices",
+			usage:       "devices",
+			description: "show the devices with the status, the open streams, the round trip time and the number of clipboards waiting to send",
+			run:         devicesCommand,
+		},
+		{
+			name:        "history",
+			usage:       "history",
+			description: "show the clipboard history and the devices each clipboard is delivered to",
+			run:         historyCommand,
+		},
+		{
+			name:        "help",
+			usage:       "help",
+			description: "show the commands",
+			run:         helpCommand,
+		},
+	}
+}
+
+// runCommand parse the input line and run the command
+func runCommand(cc *crossclipboard.CrossClipboard, input string) {
+	fields := strings.Fields(input)
+	if len(fields) == 0 {
+		return
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != fields[0] {
+			continue
+		}
+		err := cmd.run(cc, fields[1:])
+		if err != nil {
+			fmt.Printf("%s: %s\n", cmd.name, err)
+		}
+		return
+	}
+	fmt.Printf("unknown command %q, enter help to show the commands\n", fields[0])
+}
+
+func devicesCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	devices := cc.DeviceManager.ListDevices()
+	if len(devices) == 0 {
+		fmt.Println("no device found")
+		return nil
+	}
+
+	for _, dv := range devices {
+		streams := "control"
+		if dv.DataStream() != nil {
+			streams = "control+data"
+		}
+		fmt.Printf("%s (%s) %s streams: %s rtt: %s queue: %d\n", dv.Name, dv.AddressInfo.ID, dv.Status, streams, dv.RTT(), cc.QueueDepth(dv))
+	}
+	return nil
+}
+
+func historyCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	history := cc.ClipboardManager.History()
+	if len(history) == 0 {
+		fmt.Println("the clipboard history is empty")
+		return nil
+	}
+
+	for i, cb := range history {
+		mimeTypes := []string{}
+		for _, f := range cb.Representations() {
+			mimeTypes = append(mimeTypes, f.MimeType)
+		}
+		kind := strings.Join(mimeTypes, ",")
+		if cb.Selection == clipboard.SelectionPrimary {
+			kind += " (primary)"
+		}
+		if cb.Device != nil {
+			fmt.Printf("%d. %s %s %d bytes from %s\n", i+1, cb.Time.Format(time.TimeOnly), kind, cb.TotalSize(), cb.Device.Name)
+			continue
+		}
+
+		applied, total := cc.ClipboardManager.DeliverySummary(cb)
+		fmt.Printf("%d. %s %s %d bytes delivered to %d/%d devices\n", i+1, cb.Time.Format(time.TimeOnly), kind, cb.TotalSize(), applied, total)
+		for _, delivery := range cc.ClipboardManager.Deliveries(cb) {
+			fmt.Printf("   %s: %s\n", delivery.Device.Name, delivery.Status)
+		}
+	}
+	return nil
+}
+
+func connectCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: connect <multiaddr>")
+	}
+	err := cc.ConnectPeer(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Printf("connecting to %s\n", args[0])
+	return nil
+}
+
+func inviteCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	ttl := defaultInviteTTL
+	if len(args) == 1 {
+		minutes, err := strconv.Atoi(args[0])
+		if err != nil || minutes <= 0 {
+			return fmt.Errorf("usage: invite [minutes]")
+		}
+		ttl = time.Duration(minutes) * time.Minute
+	}
+
+	token, err := cc.CreateInvite(ttl)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("invite token, valid until %s:\n%s\n", time.Now().Add(ttl).Format(time.Kitchen), token)
+	qrterminal.GenerateHalfBlock(token, qrterminal.L, os.Stdout)
+	fmt.Println("on the new device, enter: join <token>")
+	return nil
+}
+
+func joinCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: join <token>")
+	}
+	err := cc.JoinGroup(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println("joining the group")
+	return nil
+}
+
+func helpCommand(cc *crossclipboard.CrossClipboard, args []string) error {
+	for _, cmd := range commands {
+		fmt.Printf("  %-24s %s\n", cmd.usage, cmd.description)
+	}
+	return nil
+}
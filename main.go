@@ -1,12 +1,14 @@
 package main
 
 import (
+	"bufio"
 	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"strings"
 
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 	"github.com/yqs112358/cross-clipboard/pkg/crossclipboard"
@@ -16,6 +18,7 @@ import (
 
 func main() {
 	configDir := flag.String("config", "", "configuration file dir")
+	flag.Usage = usage
 	flag.Parse()
 
 	cfg, err := config.LoadConfig(*configDir)
@@ -23,6 +26,22 @@ func main() {
 		log.Fatal(err)
 	}
 
+	switch flag.Arg(0) {
+	case "":
+	case "relay":
+		runRelay(cfg)
+		return
+	case "rendezvous":
+		runRendezvous(cfg)
+		return
+	case "psk":
+		runPSK(cfg, flag.Arg(1) == "new")
+		return
+	default:
+		usage()
+		os.Exit(2)
+	}
+
 	crossClipboard, err := crossclipboard.NewCrossClipboard(cfg)
 	if err != nil {
 		log.Fatal(err)
@@ -32,6 +51,13 @@ func main() {
 	exitSignal := make(chan os.Signal, 1)
 	signal.Notify(exitSignal, os.Interrupt)
 
+	// Read user input lines, used for answering prompts and commands
+	inputChan := make(chan string)
+	go readInput(inputChan)
+
+	// the device waiting for the user to answer the trust prompt
+	var pendingDevice *device.Device
+
 	for {
 		select {
 		case l := <-crossClipboard.LogChan:
@@ -45,22 +71,56 @@ func main() {
 		case <-crossClipboard.ClipboardManager.ClipboardsHistoryUpdated:
 			// log.Printf("clipboard history updated, history size %d", len(crossClipboard.ClipboardManager.ClipboardsHistory))
 		case <-crossClipboard.DeviceManager.DevicesUpdated:
-			for _, dv := range crossClipboard.DeviceManager.Devices {
+			if pendingDevice != nil {
+				continue
+			}
+			for _, dv := range crossClipboard.DeviceManager.ListDevices() {
+				keyChanged := dv.Status == device.StatusKeyChanged
+				if !keyChanged && (dv.Status != device.StatusPending || dv.IsTrusted()) {
+					continue
+				}
+				// the pending device restored from the devices file is asked when it connects
+				if dv.ControlStream == nil {
+					continue
+				}
+				code, err := crossClipboard.PairingCode(dv)
+				if err != nil {
+					log.Println(fmt.Errorf("can not create pairing code for device %s: %w", dv.Name, err))
+					continue
+				}
+				pendingDevice = dv
+				if keyChanged {
+					fmt.Printf("WARNING: device %s (%s) presented a different key, someone may be impersonating it\n", dv.Name, dv.OS)
+					fmt.Printf("make sure the device shows the same code: %s\n", code)
+					fmt.Printf("approve the new key (y/N)")
+					break
+				}
+				fmt.Printf("device %s (%s) wanted to connect\n", dv.Name, dv.OS)
+				fmt.Printf("make sure the device shows the same code: %s\n", code)
+				fmt.Printf("trust the device (Y/n)")
+				break
+			}
+		case input := <-inputChan:
+			if pendingDevice != nil {
+				dv := pendingDevice
+				pendingDevice = nil
+				answer := strings.TrimSpace(input)
+				// a changed key must be approved explicitly
+				if answer == "n" || (dv.Status == device.StatusKeyChanged && answer != "y") {
+					crossClipboard.BlockDevice(dv)
+					continue
+				}
+				err = crossClipboard.TrustDevice(dv)
+				if err != nil {
+					log.Println(fmt.Errorf("can not trust device: %w", err))
+					continue
+				}
 				if dv.Status == device.StatusPending {
-					fmt.Printf("device %s wanted to connect (Y/n)", dv.Name)
-					var input string
-					fmt.Scanln(&input)
-					if input == "n" {
-						dv.Block()
-					} else {
-						err = dv.Trust()
-						if err != nil {
-							log.Println(fmt.Errorf("can not trust device: %w", err))
-						}
-					}
-					crossClipboard.DeviceManager.UpdateDevice(dv)
+					fmt.Printf("waiting for device %s to confirm the pairing\n", dv.Name)
 				}
+				continue
 			}
+			runCommand(crossClipboard, input)
 		case exit := <-exitSignal:
 			log.Printf("got %s signal. aborting...\n", exit)
 			err := crossClipboard.Stop()
@@ -71,3 +131,21 @@ func main() {
 		}
 	}
 }
+
+// usage print the usage of the flags and the modes
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [mode]\n\nModes:\n", os.Args[0])
+	fmt.Fprintf(flag.CommandLine.Output(), "  (none)\tsync the clipboard with the devices in the group\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  relay\trun a circuit relay for the group\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  rendezvous\trun a rendezvous server for the group\n")
+	fmt.Fprintf(flag.CommandLine.Output(), "  psk [new]\tshow the pre-shared key of the group, generate a new one if there is none or new is given\n\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+// readInput read lines from stdin and send them to the input channel
+func readInput(inputChan chan<- string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		inputChan <- scanner.Text()
+	}
+}
@@ -38,9 +38,14 @@ type CrossClipbardMobile struct {
 
 func (c *CrossClipbardMobile) Start() {
 	cfg := &config.Config{
-		GroupName:  "default",
-		ListenHost: "0.0.0.0",
-		ListenPort: 4001,
+		GroupName: "default",
+		Discovery: config.DiscoveryConfig{
+			MDNS: config.MDNSConfig{
+				Enabled:    true,
+				ListenHost: "0.0.0.0",
+				ListenPort: 4001,
+			},
+		},
 	}
 	crossclipboard.NewCrossClipboard(cfg)
 }
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/utils/stringutil"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const auditFileName = "audit.log"
+
+// Event type of security event
+type Event string
+
+const (
+	// EventKeyChanged a known device presented a different public key
+	EventKeyChanged Event = "key_changed"
+	// EventKeyApproved the user approved the changed public key of a device
+	EventKeyApproved Event = "key_approved"
+	// EventReplayRejected a message from a device is rejected as replayed
+	EventReplayRejected Event = "replay_rejected"
+)
+
+// Entry one line of the audit log
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Event   Event             `json:"event"`
+	PeerID  string            `json:"peerId"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Logger append security events to the audit log in the config directory, one json entry per line
+type Logger struct {
+	filePath string
+
+	mu sync.Mutex
+}
+
+// NewLogger create a new audit logger
+func NewLogger(configDirPath string) *Logger {
+	return &Logger{
+		filePath: stringutil.JoinURL(configDirPath, auditFileName),
+	}
+}
+
+// Log append the event of the peer to the audit log
+func (l *Logger) Log(event Event, peerID string, details map[string]string) error {
+	b, err := json.Marshal(Entry{
+		Time:    time.Now(),
+		Event:   event,
+		PeerID:  peerID,
+		Details: details,
+	})
+	if err != nil {
+		return xerror.NewRuntimeError("can not marshal audit entry").Wrap(err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return xerror.NewRuntimeError("can not open audit log").Wrap(err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(b, '\n'))
+	if err != nil {
+		return xerror.NewRuntimeError("can not write audit log").Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	l := NewLogger(dir)
+
+	tests := []struct {
+		event   Event
+		peerID  string
+		details map[string]string
+	}{
+		{
+			event:   EventKeyChanged,
+			peerID:  "peer-a",
+			details: map[string]string{"old": "aa", "new": "bb"},
+		},
+		{
+			event:  EventKeyApproved,
+			peerID: "peer-a",
+		},
+	}
+
+	for _, test := range tests {
+		if err := l.Log(test.event, test.peerID, test.details); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(l.filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for _, test := range tests {
+		if !scanner.Scan() {
+			t.Fatal("missing audit entry")
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Event != test.event || entry.PeerID != test.peerID || !reflect.DeepEqual(entry.Details, test.details) {
+			t.Errorf("got %+v, wanted event %s peer %s details %v", entry, test.event, test.peerID, test.details)
+		}
+	}
+	if scanner.Scan() {
+		t.Errorf("got unexpected audit entry %s", scanner.Text())
+	}
+}
@@ -0,0 +1,76 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// ClipboardBackend access the clipboard of the os, each format is keyed by the mime type
+type ClipboardBackend interface {
+	// Formats returns the mime types supported by the backend
+	Formats() []string
+	// Read returns the data of the format on the clipboard, nil if the clipboard doesn't have the format
+	Read(mimeType string) ([]byte, error)
+	// Write replace the clipboard with the data of the format
+	Write(mimeType string, data []byte) error
+	// Watch returns a channel receiving the data of the format when it's changed, the channel is closed when ctx is done
+	Watch(ctx context.Context, mimeType string) <-chan []byte
+}
+
+// FormatsWriter a backend which offers several formats in one copy, like an application copying rich text
+type FormatsWriter interface {
+	// WriteFormats replace the clipboard with the formats copied together
+	WriteFormats(formats []Format) error
+}
+
+// supports returns true if the backend supports the format
+func supports(backend ClipboardBackend, mimeType string) bool {
+	for _, f := range backend.Formats() {
+		if f == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// isEmptySelection returns true if the command exited with one of the messages of an empty clipboard
+// or a clipboard without the format, other errors are failures of the command
+func isEmptySelection(err error, messages ...string) bool {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return false
+	}
+	for _, message := range messages {
+		if bytes.Contains(exitErr.Stderr, []byte(message)) {
+			return true
+		}
+	}
+	return false
+}
+
+// names of the clipboard backends in the config
+const (
+	// BackendAuto the wayland clipboard in a wayland session, otherwise the system clipboard,
+	// or the memory clipboard when neither is available
+	BackendAuto    = "auto"
+	BackendSystem  = "system"
+	BackendWayland = "wayland"
+	BackendMemory  = "memory"
+)
+
+// NewBackend create the clipboard backend by the name, auto is resolved by the caller
+func NewBackend(name string) (ClipboardBackend, error) {
+	switch name {
+	case BackendSystem:
+		return NewSystemBackend()
+	case BackendWayland:
+		return NewWaylandBackend()
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	}
+	return nil, xerror.NewRuntimeErrorf("unknown clipboard backend %q", name)
+}
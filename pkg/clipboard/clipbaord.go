@@ -13,26 +13,61 @@ type Clipboard struct {
 	Data    []byte
 	Size    uint32
 	Time    time.Time
-	Device  *device.Device
+	Device  *device.Device // the device sent this clipboard, nil if it's from this device
+
+	// Selection the selection the clipboard is copied from
+	Selection Selection
+
+	// Formats the other representations of the clipboard besides the data, e.g. text/html copied from a browser
+	Formats []Format
+
+	// Deliveries the devices this clipboard is sent to, guarded by the clipboard manager
+	Deliveries []*Delivery
 }
 
 // ToProtobuf convert Clipboard to protocol buffer ClipboardData
 func (c Clipboard) ToProtobuf() *protobuf.ClipboardData {
 	return &protobuf.ClipboardData{
-		IsImage:  c.IsImage,
-		Data:     c.Data,
-		DataSize: c.Size,
-		Time:     c.Time.Unix(),
+		IsImage:   c.IsImage,
+		Data:      c.Data,
+		DataSize:  c.Size,
+		Time:      c.Time.Unix(),
+		Formats:   formatsToProtobuf(c.Formats),
+		Selection: protobuf.Selection(c.Selection),
 	}
 }
 
 // FromProtobuf convert protobuf.ClipboardData to Clipboard struct
 func FromProtobuf(cd *protobuf.ClipboardData, dv *device.Device) Clipboard {
 	return Clipboard{
-		IsImage: cd.IsImage,
-		Data:    cd.Data,
-		Size:    cd.DataSize,
-		Time:    time.UnixMicro(cd.Time),
-		Device:  dv,
+		IsImage:   cd.IsImage,
+		Data:      cd.Data,
+		Size:      cd.DataSize,
+		Time:      time.UnixMicro(cd.Time),
+		Device:    dv,
+		Formats:   formatsFromProtobuf(cd.Formats),
+		Selection: Selection(cd.Selection),
+	}
+}
+
+func formatsToProtobuf(formats []Format) []*protobuf.Format {
+	if len(formats) == 0 {
+		return nil
+	}
+	pbFormats := make([]*protobuf.Format, 0, len(formats))
+	for _, f := range formats {
+		pbFormats = append(pbFormats, &protobuf.Format{MimeType: f.MimeType, Data: f.Data})
+	}
+	return pbFormats
+}
+
+func formatsFromProtobuf(pbFormats []*protobuf.Format) []Format {
+	if len(pbFormats) == 0 {
+		return nil
+	}
+	formats := make([]Format, 0, len(pbFormats))
+	for _, f := range pbFormats {
+		formats = append(formats, Format{MimeType: f.MimeType, Data: f.Data})
 	}
+	return formats
 }
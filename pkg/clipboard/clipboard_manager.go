@@ -3,40 +3,109 @@ package clipboard
 import (
 	"bytes"
 	"context"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
-	"golang.design/x/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 )
 
 // ClipboardManager struct for clipbaord manager
 type ClipboardManager struct {
-	config *config.Config
+	config  *config.Config
+	backend ClipboardBackend
+	primary ClipboardBackend // nil if the primary selection is not synced
 
-	ReadTextChannel          <-chan []byte
-	ReadImageChannel         <-chan []byte
+	// ReadChannel the clipboard copied on this device with all formats supported by the backend
+	ReadChannel              <-chan *Clipboard
 	ClipboardsHistory        []*Clipboard
 	ClipboardsHistoryUpdated chan struct{}
 	receivedClipboard        *Clipboard
-}
 
-// NewClipboardManager create new clipbaord manager
-func NewClipboardManager(cfg *config.Config) *ClipboardManager {
-	err := clipboard.Init()
-	if err != nil {
-		panic(err)
-	}
+	deliveryMu sync.RWMutex
+}
 
-	textCh := clipboard.Watch(context.Background(), clipboard.FmtText)
-	imgCh := clipboard.Watch(context.Background(), clipboard.FmtImage)
+// watchFormats the formats watched for the changes, the other formats are read along with them
+var watchFormats = []string{MimeTextPlain, MimeImagePNG}
 
-	return &ClipboardManager{
+// NewClipboardManager create new clipbaord manager of the clipboard backend,
+// primary is the backend of the primary selection, nil to not sync the primary selection
+func NewClipboardManager(cfg *config.Config, backend ClipboardBackend, primary ClipboardBackend) *ClipboardManager {
+	c := &ClipboardManager{
 		config:                   cfg,
-		ReadTextChannel:          textCh,
-		ReadImageChannel:         imgCh,
-		ClipboardsHistoryUpdated: make(chan struct{}),
+		backend:                  backend,
+		primary:                  primary,
+		ClipboardsHistoryUpdated: make(chan struct{}, 1),
 		ClipboardsHistory:        []*Clipboard{},
 	}
+	backends := map[Selection]ClipboardBackend{SelectionClipboard: backend}
+	if primary != nil {
+		backends[SelectionPrimary] = primary
+	}
+	c.ReadChannel = watch(context.Background(), backends, c.isMerged())
+	return c
+}
+
+// watch returns a channel receiving the clipboard when a watched format of a selection is changed,
+// the channel is closed when all watches ended. when the selections are merged, both are sent as the clipboard
+// so every device receives them, and the same data copied to the other selection is not sent again,
+// e.g. the selected text copied to the clipboard
+func watch(ctx context.Context, backends map[Selection]ClipboardBackend, merged bool) <-chan *Clipboard {
+	out := make(chan *Clipboard)
+	var wg sync.WaitGroup
+	var lastMu sync.Mutex
+	var last []byte
+	for selection, backend := range backends {
+		for _, mimeType := range watchFormats {
+			if !supports(backend, mimeType) {
+				continue
+			}
+			wg.Add(1)
+			go func(selection Selection, backend ClipboardBackend, mimeType string, changes <-chan []byte) {
+				defer wg.Done()
+				for data := range changes {
+					formats := append([]Format{{MimeType: mimeType, Data: data}}, readOtherFormats(backend)...)
+					cb := NewClipboard(formats, time.Now())
+					cb.Selection = selection
+					if merged {
+						cb.Selection = SelectionClipboard
+					}
+
+					lastMu.Lock()
+					if merged && bytes.Equal(cb.Data, last) {
+						lastMu.Unlock()
+						continue
+					}
+					last = cb.Data
+					out <- cb
+					lastMu.Unlock()
+				}
+			}(selection, backend, mimeType, backend.Watch(ctx, mimeType))
+		}
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// readOtherFormats read the formats not watched, e.g. the html copied with the text
+func readOtherFormats(backend ClipboardBackend) []Format {
+	formats := []Format{}
+	for _, mimeType := range backend.Formats() {
+		if slices.Contains(watchFormats, mimeType) {
+			continue
+		}
+		data, err := backend.Read(mimeType)
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		formats = append(formats, Format{MimeType: mimeType, Data: data})
+	}
+	return formats
 }
 
 // limitAppend append and rotate when limit
@@ -49,23 +118,86 @@ func limitAppend[T any](limit int, slice []T, new T) []T {
 	return slice
 }
 
-// WriteClipboard write os clipbaord
-func (c *ClipboardManager) WriteClipboard(newClipboard Clipboard) {
+// SyncsSelection returns true if the clipboard of the selection is synced
+func (c *ClipboardManager) SyncsSelection(selection Selection) bool {
+	return selection == SelectionClipboard || c.primary != nil
+}
+
+// isMerged returns true if the primary selection is synced as the same clipboard
+func (c *ClipboardManager) isMerged() bool {
+	return c.primary != nil && c.config.Clipboard.Primary == PrimaryMerged
+}
+
+// selectionBackends returns the backends the clipboard of the selection is written to
+func (c *ClipboardManager) selectionBackends(selection Selection) []ClipboardBackend {
+	switch {
+	case c.isMerged():
+		return []ClipboardBackend{c.backend, c.primary}
+	case selection == SelectionPrimary && c.primary != nil:
+		return []ClipboardBackend{c.primary}
+	case selection == SelectionClipboard:
+		return []ClipboardBackend{c.backend}
+	}
+	return nil
+}
+
+// WriteClipboard write os clipbaord of the selection with the representations supported by the backend,
+// all representations are kept in the history
+func (c *ClipboardManager) WriteClipboard(newClipboard Clipboard) error {
+	backends := c.selectionBackends(newClipboard.Selection)
+	if len(backends) == 0 {
+		return xerror.NewRuntimeErrorf("the %s selection is not synced", newClipboard.Selection)
+	}
+
 	c.receivedClipboard = &newClipboard
 
 	c.AddClipboardToHistory(&newClipboard)
 
-	if newClipboard.IsImage {
-		clipboard.Write(clipboard.FmtImage, newClipboard.Data)
-		return
+	for _, backend := range backends {
+		err := writeBackend(backend, newClipboard)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBackend write all representations supported by the backend in one copy if the backend can offer several formats,
+// otherwise the first supported representation, e.g. wl-copy and xclip offer one type in a copy
+func writeBackend(backend ClipboardBackend, cb Clipboard) error {
+	formats := []Format{}
+	for _, f := range cb.Representations() {
+		if !supports(backend, f.MimeType) || slices.ContainsFunc(formats, func(w Format) bool { return w.MimeType == f.MimeType }) {
+			continue
+		}
+		formats = append(formats, f)
 	}
-	clipboard.Write(clipboard.FmtText, newClipboard.Data)
+	// no representation is supported by the backend
+	if len(formats) == 0 {
+		return nil
+	}
+
+	if writer, ok := backend.(FormatsWriter); ok {
+		return writer.WriteFormats(formats)
+	}
+	return backend.Write(formats[0].MimeType, formats[0].Data)
 }
 
 // AddClipboardToHistory add clipbaord to clipbaord history
 func (c *ClipboardManager) AddClipboardToHistory(newClipboard *Clipboard) {
+	c.deliveryMu.Lock()
 	c.ClipboardsHistory = limitAppend(c.config.MaxHistory, c.ClipboardsHistory, newClipboard)
-	c.ClipboardsHistoryUpdated <- struct{}{}
+	c.deliveryMu.Unlock()
+
+	c.notifyHistoryUpdated()
+}
+
+// notifyHistoryUpdated notify the history is updated without blocking, the pending notification is enough
+func (c *ClipboardManager) notifyHistoryUpdated() {
+	select {
+	case c.ClipboardsHistoryUpdated <- struct{}{}:
+	default:
+	}
 }
 
 // IsReceivedDevice returns true if it's the same device with the received clipboard
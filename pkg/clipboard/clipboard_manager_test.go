@@ -3,6 +3,9 @@ package clipboard
 import (
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
 )
 
 func TestLimitAppend(t *testing.T) {
@@ -34,3 +37,91 @@ func TestLimitAppend(t *testing.T) {
 		}
 	}
 }
+
+func TestReadChannel(t *testing.T) {
+	backend := NewMemoryBackend()
+	c := NewClipboardManager(&config.Config{MaxHistory: 10}, backend, nil)
+
+	backend.Set(Format{MimeType: MimeTextPlain, Data: []byte("a")}, Format{MimeType: MimeTextHTML, Data: []byte("<b>a</b>")})
+	cb := <-c.ReadChannel
+
+	if cb.IsImage || string(cb.Data) != "a" {
+		t.Errorf("got data %q, wanted %q", cb.Data, "a")
+	}
+	if html, ok := cb.Format(MimeTextHTML); !ok || string(html) != "<b>a</b>" {
+		t.Errorf("got html %q, wanted %q", html, "<b>a</b>")
+	}
+}
+
+// textBackend a memory clipboard supporting only the text
+type textBackend struct {
+	*MemoryBackend
+}
+
+func (textBackend) Formats() []string {
+	return []string{MimeTextPlain}
+}
+
+func TestWriteClipboard(t *testing.T) {
+	tests := []struct {
+		name     string
+		limited  bool
+		cb       Clipboard
+		mimeType string
+		want     string
+		wantHTML string
+	}{
+		{
+			name:     "data",
+			cb:       *NewClipboard([]Format{{MimeType: MimeTextPlain, Data: []byte("a")}, {MimeType: MimeTextHTML, Data: []byte("<b>a</b>")}}, time.Now()),
+			mimeType: MimeTextPlain,
+			want:     "a",
+			wantHTML: "<b>a</b>",
+		},
+		{
+			name:     "image",
+			cb:       *NewClipboard([]Format{{MimeType: MimeImagePNG, Data: []byte("png")}}, time.Now()),
+			mimeType: MimeImagePNG,
+			want:     "png",
+		},
+		{
+			name:     "unsupported data",
+			limited:  true,
+			cb:       Clipboard{Data: []byte("png"), IsImage: true, Formats: []Format{{MimeType: MimeTextPlain, Data: []byte("a")}}},
+			mimeType: MimeTextPlain,
+			want:     "a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			memory := NewMemoryBackend()
+			var backend ClipboardBackend = memory
+			if test.limited {
+				backend = textBackend{memory}
+			}
+			c := &ClipboardManager{
+				config:                   &config.Config{MaxHistory: 10},
+				backend:                  backend,
+				ClipboardsHistoryUpdated: make(chan struct{}, 1),
+			}
+
+			err := c.WriteClipboard(test.cb)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := memory.Read(test.mimeType)
+			if string(got) != test.want {
+				t.Errorf("got %q, wanted %q", got, test.want)
+			}
+			// the representations are written in one copy
+			html, _ := memory.Read(MimeTextHTML)
+			if string(html) != test.wantHTML {
+				t.Errorf("got html %q, wanted %q", html, test.wantHTML)
+			}
+			if !c.IsReceivedClipboard(test.cb.Data) {
+				t.Error("the written clipboard is not the received clipboard")
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package clipboard
+
+import "github.com/yqs112358/cross-clipboard/pkg/device"
+
+// DeliveryStatus status of the clipboard sent to a device
+type DeliveryStatus string
+
+const (
+	DeliveryPending          DeliveryStatus = "pending"            // sent, waiting for the receipt
+	DeliveryApplied          DeliveryStatus = "applied"            // the device applied it to its clipboard
+	DeliveryRejectedTooLarge DeliveryStatus = "rejected_too_large" // larger than the max size of the device
+	DeliveryDecryptFailed    DeliveryStatus = "decrypt_failed"     // the device can not decrypt it
+	DeliveryFiltered         DeliveryStatus = "filtered"           // the device ignored it, e.g. it doesn't trust this device
+	DeliveryFailed           DeliveryStatus = "failed"             // failed to send or the device failed to apply it
+	DeliveryDropped          DeliveryStatus = "dropped"            // dropped from the send queue for a newer clipboard or the device disconnected
+)
+
+// Delivery the clipboard sent to a device
+type Delivery struct {
+	Device    *device.Device
+	MessageID string
+	Status    DeliveryStatus
+}
+
+// TrackDelivery record the clipboard is sent to the device by the message
+func (c *ClipboardManager) TrackDelivery(cb *Clipboard, dv *device.Device, messageID string) {
+	c.deliveryMu.Lock()
+	defer c.deliveryMu.Unlock()
+
+	cb.Deliveries = append(cb.Deliveries, &Delivery{
+		Device:    dv,
+		MessageID: messageID,
+		Status:    DeliveryPending,
+	})
+}
+
+// UpdateDelivery update the status of the message sent to the device in the clipboard history,
+// returns false if the message is not found
+func (c *ClipboardManager) UpdateDelivery(dv *device.Device, messageID string, status DeliveryStatus) bool {
+	c.deliveryMu.Lock()
+	defer c.deliveryMu.Unlock()
+
+	for _, cb := range c.ClipboardsHistory {
+		for _, delivery := range cb.Deliveries {
+			if delivery.MessageID == messageID && delivery.Device.AddressInfo.ID == dv.AddressInfo.ID {
+				delivery.Status = status
+				c.notifyHistoryUpdated()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// History returns a copy of the clipboard history
+func (c *ClipboardManager) History() []*Clipboard {
+	c.deliveryMu.RLock()
+	defer c.deliveryMu.RUnlock()
+	return append([]*Clipboard{}, c.ClipboardsHistory...)
+}
+
+// Deliveries returns a copy of the deliveries of the clipboard
+func (c *ClipboardManager) Deliveries(cb *Clipboard) []Delivery {
+	c.deliveryMu.RLock()
+	defer c.deliveryMu.RUnlock()
+
+	deliveries := make([]Delivery, 0, len(cb.Deliveries))
+	for _, delivery := range cb.Deliveries {
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries
+}
+
+// DeliverySummary returns the number of devices applied the clipboard and the number of devices it's sent to
+func (c *ClipboardManager) DeliverySummary(cb *Clipboard) (applied int, total int) {
+	c.deliveryMu.RLock()
+	defer c.deliveryMu.RUnlock()
+
+	for _, delivery := range cb.Deliveries {
+		if delivery.Status == DeliveryApplied {
+			applied++
+		}
+	}
+	return applied, len(cb.Deliveries)
+}
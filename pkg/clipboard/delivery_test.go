@@ -0,0 +1,59 @@
+package clipboard
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+)
+
+func TestDelivery(t *testing.T) {
+	c := &ClipboardManager{
+		config:                   &config.Config{MaxHistory: 10},
+		ClipboardsHistoryUpdated: make(chan struct{}, 1),
+	}
+	devices := []*device.Device{
+		{AddressInfo: peer.AddrInfo{ID: "peer-a"}},
+		{AddressInfo: peer.AddrInfo{ID: "peer-b"}},
+		{AddressInfo: peer.AddrInfo{ID: "peer-c"}},
+	}
+
+	cb := &Clipboard{Data: []byte("text")}
+	c.AddClipboardToHistory(cb)
+	for i, dv := range devices {
+		c.TrackDelivery(cb, dv, string(rune('1'+i)))
+	}
+
+	tests := []struct {
+		dv          *device.Device
+		messageID   string
+		status      DeliveryStatus
+		wantUpdated bool
+		wantApplied int
+	}{
+		{dv: devices[0], messageID: "1", status: DeliveryApplied, wantUpdated: true, wantApplied: 1},
+		{dv: devices[1], messageID: "2", status: DeliveryRejectedTooLarge, wantUpdated: true, wantApplied: 1},
+		// the message id is of another device
+		{dv: devices[2], messageID: "1", status: DeliveryApplied, wantUpdated: false, wantApplied: 1},
+		{dv: devices[2], messageID: "3", status: DeliveryApplied, wantUpdated: true, wantApplied: 2},
+	}
+
+	for _, test := range tests {
+		updated := c.UpdateDelivery(test.dv, test.messageID, test.status)
+		if updated != test.wantUpdated {
+			t.Errorf("got updated %v for %s message %s, wanted %v", updated, test.dv.AddressInfo.ID, test.messageID, test.wantUpdated)
+		}
+		applied, total := c.DeliverySummary(cb)
+		if applied != test.wantApplied || total != len(devices) {
+			t.Errorf("got delivered %d/%d, wanted %d/%d", applied, total, test.wantApplied, len(devices))
+		}
+	}
+
+	wantStatus := []DeliveryStatus{DeliveryApplied, DeliveryRejectedTooLarge, DeliveryApplied}
+	for i, delivery := range c.Deliveries(cb) {
+		if delivery.Status != wantStatus[i] {
+			t.Errorf("got status %s for %s, wanted %s", delivery.Status, delivery.Device.AddressInfo.ID, wantStatus[i])
+		}
+	}
+}
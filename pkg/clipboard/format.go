@@ -0,0 +1,138 @@
+package clipboard
+
+import (
+	"html"
+	"strings"
+	"time"
+)
+
+// mime types of the clipboard formats
+const (
+	MimeTextPlain = "text/plain"
+	MimeTextHTML  = "text/html"
+	MimeTextRTF   = "text/rtf"
+	MimeURIList   = "text/uri-list"
+	MimeImagePNG  = "image/png"
+	// MimeFiles the tar archive of the copied files and directories, replacing the uri list of the local paths
+	MimeFiles = "application/x-cross-clipboard-files"
+)
+
+// Format a representation of the clipboard keyed by the mime type
+type Format struct {
+	MimeType string
+	Data     []byte
+}
+
+// NewClipboard create a clipboard of the representations copied together,
+// the plain text or png image is the data written by the backends supporting only text and image,
+// without them the plain text is converted from a text format like html, the others are kept in formats
+func NewClipboard(formats []Format, t time.Time) *Clipboard {
+	cb := &Clipboard{Time: t}
+
+	primary := -1
+	for i, f := range formats {
+		if f.MimeType == MimeImagePNG {
+			primary = i
+			cb.IsImage = true
+			break
+		}
+		if f.MimeType == MimeTextPlain && primary < 0 {
+			primary = i
+		}
+	}
+
+	for i, f := range formats {
+		if i == primary {
+			cb.Data = f.Data
+			continue
+		}
+		cb.Formats = append(cb.Formats, f)
+	}
+
+	if primary < 0 {
+		for _, f := range cb.Formats {
+			if text, ok := plainText(f); ok {
+				cb.Data = text
+				break
+			}
+		}
+	}
+	cb.Size = uint32(len(cb.Data))
+	return cb
+}
+
+// plainText returns the fallback plain text of a text format, rtf and unknown formats have no fallback
+func plainText(f Format) ([]byte, bool) {
+	switch f.MimeType {
+	case MimeTextHTML:
+		return []byte(stripTags(string(f.Data))), true
+	case MimeURIList:
+		// the lines starting with # are comments
+		lines := []string{}
+		for _, line := range strings.Split(string(f.Data), "\n") {
+			line = strings.TrimRight(line, "\r")
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			lines = append(lines, line)
+		}
+		return []byte(strings.Join(lines, "\n")), true
+	}
+	if strings.HasPrefix(f.MimeType, "text/") && f.MimeType != MimeTextRTF {
+		return f.Data, true
+	}
+	return nil, false
+}
+
+// stripTags returns the text of the html without the tags
+func stripTags(s string) string {
+	var b strings.Builder
+	inTag := false
+	for _, r := range s {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(r)
+		}
+	}
+	return strings.TrimSpace(html.UnescapeString(b.String()))
+}
+
+// MimeType returns the mime type of the clipboard data
+func (c *Clipboard) MimeType() string {
+	if c.IsImage {
+		return MimeImagePNG
+	}
+	return MimeTextPlain
+}
+
+// Representations returns the data and the other formats of the clipboard
+func (c *Clipboard) Representations() []Format {
+	formats := make([]Format, 0, len(c.Formats)+1)
+	if len(c.Data) > 0 {
+		formats = append(formats, Format{MimeType: c.MimeType(), Data: c.Data})
+	}
+	return append(formats, c.Formats...)
+}
+
+// Format returns the data of the representation by the mime type
+func (c *Clipboard) Format(mimeType string) ([]byte, bool) {
+	for _, f := range c.Representations() {
+		if f.MimeType == mimeType {
+			return f.Data, true
+		}
+	}
+	return nil, false
+}
+
+// TotalSize returns the size of all representations
+func (c *Clipboard) TotalSize() int {
+	size := len(c.Data)
+	for _, f := range c.Formats {
+		size += len(f.Data)
+	}
+	return size
+}
@@ -0,0 +1,78 @@
+package clipboard
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNewClipboard(t *testing.T) {
+	html := Format{MimeType: MimeTextHTML, Data: []byte("<p>hello <b>world</b> &amp; all</p>")}
+	text := Format{MimeType: MimeTextPlain, Data: []byte("hello world & all")}
+	png := Format{MimeType: MimeImagePNG, Data: []byte("\x89PNG")}
+	rtf := Format{MimeType: MimeTextRTF, Data: []byte(`{\rtf1 hello}`)}
+	uris := Format{MimeType: MimeURIList, Data: []byte("# comment\r\nfile:///a.txt\r\nfile:///b.txt\r\n")}
+	custom := Format{MimeType: "application/x-custom", Data: []byte{1, 2}}
+
+	tests := []struct {
+		name        string
+		formats     []Format
+		wantIsImage bool
+		wantData    string
+		wantFormats []Format
+	}{
+		{name: "text only", formats: []Format{text}, wantData: "hello world & all"},
+		{name: "text with html", formats: []Format{html, text}, wantData: "hello world & all", wantFormats: []Format{html}},
+		{name: "image preferred", formats: []Format{text, png}, wantIsImage: true, wantData: "\x89PNG", wantFormats: []Format{text}},
+		{name: "html without text", formats: []Format{html}, wantData: "hello world & all", wantFormats: []Format{html}},
+		{name: "uri list without text", formats: []Format{uris}, wantData: "file:///a.txt\nfile:///b.txt", wantFormats: []Format{uris}},
+		{name: "rtf has no fallback", formats: []Format{rtf}, wantData: "", wantFormats: []Format{rtf}},
+		{name: "custom has no fallback", formats: []Format{custom}, wantData: "", wantFormats: []Format{custom}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cb := NewClipboard(test.formats, time.Now())
+			if cb.IsImage != test.wantIsImage {
+				t.Errorf("got is image %v, wanted %v", cb.IsImage, test.wantIsImage)
+			}
+			if string(cb.Data) != test.wantData {
+				t.Errorf("got data %q, wanted %q", cb.Data, test.wantData)
+			}
+			if int(cb.Size) != len(test.wantData) {
+				t.Errorf("got size %d, wanted %d", cb.Size, len(test.wantData))
+			}
+			if !reflect.DeepEqual(cb.Formats, test.wantFormats) {
+				t.Errorf("got formats %v, wanted %v", cb.Formats, test.wantFormats)
+			}
+		})
+	}
+}
+
+func TestClipboardFormatsProtobuf(t *testing.T) {
+	html := Format{MimeType: MimeTextHTML, Data: []byte("<b>hello</b>")}
+	cb := NewClipboard([]Format{{MimeType: MimeTextPlain, Data: []byte("hello")}, html}, time.Now())
+
+	b, err := proto.Marshal(cb.ToProtobuf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cd := &protobuf.ClipboardData{}
+	if err := proto.Unmarshal(b, cd); err != nil {
+		t.Fatal(err)
+	}
+	got := FromProtobuf(cd, nil)
+
+	if !reflect.DeepEqual(got.Representations(), cb.Representations()) {
+		t.Errorf("got representations %v, wanted %v", got.Representations(), cb.Representations())
+	}
+	if data, ok := got.Format(MimeTextHTML); !ok || string(data) != "<b>hello</b>" {
+		t.Errorf("got html %q %v, wanted %q", data, ok, "<b>hello</b>")
+	}
+	if got.TotalSize() != len("hello")+len("<b>hello</b>") {
+		t.Errorf("got total size %d", got.TotalSize())
+	}
+}
@@ -0,0 +1,106 @@
+package clipboard
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBackend a clipboard in memory supporting any format, for the tests and the devices without a display
+type MemoryBackend struct {
+	formats  map[string][]byte
+	watchers map[string][]chan []byte
+	primary  *MemoryBackend // the primary selection, created when it's used
+
+	mu sync.Mutex
+}
+
+// NewMemoryBackend create a new memory clipboard
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		formats:  make(map[string][]byte),
+		watchers: make(map[string][]chan []byte),
+	}
+}
+
+// Formats returns the common formats, the memory clipboard can also keep any other format
+func (m *MemoryBackend) Formats() []string {
+	return []string{MimeTextPlain, MimeImagePNG, MimeTextHTML, MimeTextRTF, MimeURIList}
+}
+
+// Primary returns the memory clipboard of the primary selection
+func (m *MemoryBackend) Primary() (ClipboardBackend, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.primary == nil {
+		m.primary = NewMemoryBackend()
+	}
+	return m.primary, nil
+}
+
+// Read returns the data of the format
+func (m *MemoryBackend) Read(mimeType string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.formats[mimeType], nil
+}
+
+// Write replace the clipboard with the data of the format
+func (m *MemoryBackend) Write(mimeType string, data []byte) error {
+	m.Set(Format{MimeType: mimeType, Data: data})
+	return nil
+}
+
+// WriteFormats replace the clipboard with the formats copied together
+func (m *MemoryBackend) WriteFormats(formats []Format) error {
+	m.Set(formats...)
+	return nil
+}
+
+// Set replace the clipboard with the formats copied together, like an application copying rich text
+func (m *MemoryBackend) Set(formats ...Format) {
+	m.mu.Lock()
+	m.formats = make(map[string][]byte, len(formats))
+	for _, f := range formats {
+		m.formats[f.MimeType] = f.Data
+	}
+	// notify under the lock to keep the order of the changes
+	for _, f := range formats {
+		for _, ch := range m.watchers[f.MimeType] {
+			sendLatest(ch, f.Data)
+		}
+	}
+	m.mu.Unlock()
+}
+
+// Watch returns a channel receiving the data of the format when it's changed
+func (m *MemoryBackend) Watch(ctx context.Context, mimeType string) <-chan []byte {
+	ch := make(chan []byte, 1)
+	m.mu.Lock()
+	m.watchers[mimeType] = append(m.watchers[mimeType], ch)
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		watchers := m.watchers[mimeType]
+		for i, other := range watchers {
+			if other == ch {
+				m.watchers[mimeType] = append(watchers[:i:i], watchers[i+1:]...)
+				break
+			}
+		}
+		m.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// sendLatest replace the change not received yet with the latest one, so writing the clipboard never blocks,
+// the caller must hold the lock of the backend
+func sendLatest(ch chan []byte, data []byte) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- data
+}
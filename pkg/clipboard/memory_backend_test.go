@@ -0,0 +1,85 @@
+package clipboard
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryBackendWatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		watch   string
+		writes  []Format
+		want    string
+		changed bool
+	}{
+		{
+			name:    "changed",
+			watch:   MimeTextPlain,
+			writes:  []Format{{MimeType: MimeTextPlain, Data: []byte("a")}},
+			want:    "a",
+			changed: true,
+		},
+		{
+			name:  "latest change",
+			watch: MimeTextPlain,
+			writes: []Format{
+				{MimeType: MimeTextPlain, Data: []byte("a")},
+				{MimeType: MimeTextPlain, Data: []byte("b")},
+			},
+			want:    "b",
+			changed: true,
+		},
+		{
+			name:   "other format",
+			watch:  MimeImagePNG,
+			writes: []Format{{MimeType: MimeTextPlain, Data: []byte("a")}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := NewMemoryBackend()
+			ctx, cancel := context.WithCancel(context.Background())
+			changes := m.Watch(ctx, test.watch)
+
+			for _, f := range test.writes {
+				if err := m.Write(f.MimeType, f.Data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cancel()
+
+			got, changed := <-changes
+			if changed != test.changed || string(got) != test.want {
+				t.Errorf("got %q %v, wanted %q %v", got, changed, test.want, test.changed)
+			}
+			if _, ok := <-changes; ok {
+				t.Error("the watch is not closed")
+			}
+		})
+	}
+}
+
+func TestMemoryBackendSet(t *testing.T) {
+	m := NewMemoryBackend()
+	m.Set(Format{MimeType: MimeTextPlain, Data: []byte("a")}, Format{MimeType: MimeTextHTML, Data: []byte("<b>a</b>")})
+	m.Set(Format{MimeType: MimeTextPlain, Data: []byte("b")})
+
+	tests := []struct {
+		mimeType string
+		want     string
+	}{
+		{mimeType: MimeTextPlain, want: "b"},
+		{mimeType: MimeTextHTML, want: ""},
+	}
+	for _, test := range tests {
+		got, err := m.Read(test.mimeType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s got %q, wanted %q", test.mimeType, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package clipboard
+
+import (
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// Selection the X11 selection the clipboard is copied from
+type Selection int
+
+const (
+	// SelectionClipboard the clipboard of copy and paste
+	SelectionClipboard Selection = iota
+	// SelectionPrimary the selected text, pasted by the middle click
+	SelectionPrimary
+)
+
+func (s Selection) String() string {
+	if s == SelectionPrimary {
+		return "primary"
+	}
+	return "clipboard"
+}
+
+// modes of syncing the primary selection in the config
+const (
+	PrimaryOff = "off"
+	// PrimarySeparate the primary selection is written to the primary selection of the devices
+	PrimarySeparate = "separate"
+	// PrimaryMerged both selections are sent as one clipboard and written to both selections of the devices
+	PrimaryMerged = "merged"
+)
+
+// primarySelector a backend having the primary selection besides the clipboard
+type primarySelector interface {
+	// Primary returns the backend of the primary selection
+	Primary() (ClipboardBackend, error)
+}
+
+// PrimaryBackend returns the backend of the primary selection of the clipboard backend
+func PrimaryBackend(backend ClipboardBackend) (ClipboardBackend, error) {
+	selector, ok := backend.(primarySelector)
+	if !ok {
+		return nil, xerror.NewRuntimeError("the clipboard backend does not have the primary selection")
+	}
+	return selector.Primary()
+}
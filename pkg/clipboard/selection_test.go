@@ -0,0 +1,115 @@
+package clipboard
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+)
+
+// newSelectionTestManager create the clipboard manager on the memory clipboard with the primary mode
+func newSelectionTestManager(t *testing.T, mode string) (*ClipboardManager, *MemoryBackend, *MemoryBackend) {
+	backend := NewMemoryBackend()
+	primary, err := backend.Primary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{MaxHistory: 10, Clipboard: config.ClipboardConfig{Primary: mode}}
+	if mode == PrimaryOff {
+		return NewClipboardManager(cfg, backend, nil), backend, primary.(*MemoryBackend)
+	}
+	return NewClipboardManager(cfg, backend, primary), backend, primary.(*MemoryBackend)
+}
+
+func TestWriteClipboardSelection(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		selection     Selection
+		wantErr       bool
+		wantClipboard string
+		wantPrimary   string
+	}{
+		{name: "off clipboard", mode: PrimaryOff, selection: SelectionClipboard, wantClipboard: "a"},
+		{name: "off primary", mode: PrimaryOff, selection: SelectionPrimary, wantErr: true},
+		{name: "separate clipboard", mode: PrimarySeparate, selection: SelectionClipboard, wantClipboard: "a"},
+		{name: "separate primary", mode: PrimarySeparate, selection: SelectionPrimary, wantPrimary: "a"},
+		{name: "merged clipboard", mode: PrimaryMerged, selection: SelectionClipboard, wantClipboard: "a", wantPrimary: "a"},
+		{name: "merged primary", mode: PrimaryMerged, selection: SelectionPrimary, wantClipboard: "a", wantPrimary: "a"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, backend, primary := newSelectionTestManager(t, test.mode)
+			cb := NewClipboard([]Format{{MimeType: MimeTextPlain, Data: []byte("a")}}, time.Now())
+			cb.Selection = test.selection
+
+			err := c.WriteClipboard(*cb)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, test.wantErr)
+			}
+			if got, _ := backend.Read(MimeTextPlain); string(got) != test.wantClipboard {
+				t.Errorf("got clipboard %q, wanted %q", got, test.wantClipboard)
+			}
+			if got, _ := primary.Read(MimeTextPlain); string(got) != test.wantPrimary {
+				t.Errorf("got primary %q, wanted %q", got, test.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestReadChannelSelection(t *testing.T) {
+	tests := []struct {
+		name   string
+		mode   string
+		writes []Selection
+		want   []Selection
+	}{
+		{
+			name:   "separate",
+			mode:   PrimarySeparate,
+			writes: []Selection{SelectionPrimary, SelectionClipboard},
+			want:   []Selection{SelectionPrimary, SelectionClipboard},
+		},
+		{
+			// the selected text copied to the clipboard is sent once, as the clipboard
+			name:   "merged",
+			mode:   PrimaryMerged,
+			writes: []Selection{SelectionPrimary, SelectionClipboard},
+			want:   []Selection{SelectionClipboard},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, backend, primary := newSelectionTestManager(t, test.mode)
+			selectionBackends := map[Selection]*MemoryBackend{SelectionClipboard: backend, SelectionPrimary: primary}
+
+			got := []Selection{}
+			for _, selection := range test.writes {
+				selectionBackends[selection].Set(Format{MimeType: MimeTextPlain, Data: []byte("a")})
+				select {
+				case cb := <-c.ReadChannel:
+					got = append(got, cb.Selection)
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, wanted %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("got %v, wanted %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectionProtobuf(t *testing.T) {
+	cb := Clipboard{Data: []byte("a"), Selection: SelectionPrimary}
+	got := FromProtobuf(cb.ToProtobuf(), nil)
+	if got.Selection != SelectionPrimary {
+		t.Errorf("got %s, wanted %s", got.Selection, SelectionPrimary)
+	}
+}
@@ -0,0 +1,93 @@
+package clipboard
+
+import (
+	"context"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+	"golang.design/x/clipboard"
+)
+
+// systemBackend the clipboard of the os by golang.design/x/clipboard, it supports only the text and png image,
+// it needs a display on linux. the other formats of the X11 clipboard are read by xclip when it's installed
+type systemBackend struct {
+	xclip ClipboardBackend // the clipboard selection by xclip, nil if xclip is not installed
+}
+
+// NewSystemBackend create the backend of the os clipboard, returns an error if the clipboard is not available
+func NewSystemBackend() (ClipboardBackend, error) {
+	err := clipboard.Init()
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not initialize the os clipboard").Wrap(err)
+	}
+	// xclip is optional, only the text and image are synced without it
+	xclip, err := newXclipBackend("clipboard")
+	if err != nil {
+		return systemBackend{}, nil
+	}
+	return systemBackend{xclip: xclip}, nil
+}
+
+func (b systemBackend) Formats() []string {
+	if b.xclip == nil {
+		return []string{MimeTextPlain, MimeImagePNG}
+	}
+	return []string{MimeTextPlain, MimeImagePNG, MimeTextHTML, MimeTextRTF, MimeURIList}
+}
+
+func (b systemBackend) Read(mimeType string) ([]byte, error) {
+	if b.isXclipFormat(mimeType) {
+		return b.xclip.Read(mimeType)
+	}
+	format, err := systemFormat(mimeType)
+	if err != nil {
+		return nil, err
+	}
+	return clipboard.Read(format), nil
+}
+
+func (b systemBackend) Write(mimeType string, data []byte) error {
+	if b.isXclipFormat(mimeType) {
+		return b.xclip.Write(mimeType, data)
+	}
+	format, err := systemFormat(mimeType)
+	if err != nil {
+		return err
+	}
+	clipboard.Write(format, data)
+	return nil
+}
+
+// isXclipFormat returns true if the format is accessed by xclip
+func (b systemBackend) isXclipFormat(mimeType string) bool {
+	if b.xclip == nil {
+		return false
+	}
+	_, err := systemFormat(mimeType)
+	return err != nil && supports(b.xclip, mimeType)
+}
+
+func (systemBackend) Watch(ctx context.Context, mimeType string) <-chan []byte {
+	format, err := systemFormat(mimeType)
+	if err != nil {
+		ch := make(chan []byte)
+		close(ch)
+		return ch
+	}
+	return clipboard.Watch(ctx, format)
+}
+
+// systemFormat returns the format of the library by the mime type
+func systemFormat(mimeType string) (clipboard.Format, error) {
+	switch mimeType {
+	case MimeTextPlain:
+		return clipboard.FmtText, nil
+	case MimeImagePNG:
+		return clipboard.FmtImage, nil
+	}
+	return 0, xerror.NewRuntimeErrorf("unsupported clipboard format %s", mimeType)
+}
+
+// Primary returns the backend of the X11 primary selection by xclip
+func (systemBackend) Primary() (ClipboardBackend, error) {
+	return newXclipBackend("primary")
+}
@@ -0,0 +1,153 @@
+package clipboard
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// waylandRestartDelay the time to wait before restarting wl-paste --watch when it exited, e.g. the compositor restarted
+const waylandRestartDelay = time.Second
+
+// waylandEmptyMessages the errors of wl-paste when the clipboard is empty or doesn't have the type
+var waylandEmptyMessages = []string{"No selection", "No suitable type of content copied", "Nothing is copied"}
+
+// waylandBackend the clipboard of a wayland compositor by the wl-copy and wl-paste commands of wl-clipboard,
+// it supports the text, image and the other text formats copied with them like html
+type waylandBackend struct {
+	primary bool // the primary selection instead of the clipboard
+}
+
+// NewWaylandBackend create the backend of the wayland clipboard, returns an error if wl-clipboard is not installed
+func NewWaylandBackend() (ClipboardBackend, error) {
+	for _, name := range []string{"wl-copy", "wl-paste"} {
+		_, err := exec.LookPath(name)
+		if err != nil {
+			return nil, xerror.NewRuntimeErrorf("can not find %s, wl-clipboard is required for the wayland clipboard", name).Wrap(err)
+		}
+	}
+	return waylandBackend{}, nil
+}
+
+func (waylandBackend) Formats() []string {
+	return []string{MimeTextPlain, MimeImagePNG, MimeTextHTML, MimeURIList, MimeTextRTF}
+}
+
+// Primary returns the backend of the primary selection
+func (waylandBackend) Primary() (ClipboardBackend, error) {
+	return waylandBackend{primary: true}, nil
+}
+
+func (b waylandBackend) Read(mimeType string) ([]byte, error) {
+	pasteType, err := waylandPasteType(mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := b.command(context.Background(), "wl-paste", "--no-newline", "--type", pasteType).Output()
+	if isEmptySelection(err, waylandEmptyMessages...) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not run wl-paste").Wrap(err)
+	}
+	return data, nil
+}
+
+func (b waylandBackend) Write(mimeType string, data []byte) error {
+	_, err := waylandPasteType(mimeType)
+	if err != nil {
+		return err
+	}
+
+	// wl-copy serves the clipboard from a forked process which keeps the output open,
+	// the output is not captured so the command returns after forking
+	cmd := b.command(context.Background(), "wl-copy", "--type", mimeType)
+	cmd.Stdin = bytes.NewReader(data)
+	err = cmd.Run()
+	if err != nil {
+		return xerror.NewRuntimeError("can not run wl-copy").Wrap(err)
+	}
+	return nil
+}
+
+func (b waylandBackend) Watch(ctx context.Context, mimeType string) <-chan []byte {
+	ch := make(chan []byte)
+	if _, err := waylandPasteType(mimeType); err != nil {
+		close(ch)
+		return ch
+	}
+
+	// the clipboard before watching is not a change
+	last, _ := b.Read(mimeType)
+	go func() {
+		defer close(ch)
+		for {
+			last = b.watchChanges(ctx, mimeType, last, ch)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(waylandRestartDelay):
+			}
+		}
+	}()
+	return ch
+}
+
+// watchChanges run wl-paste --watch until it exits and send the data of the format when the clipboard is changed,
+// returns the last data sent
+func (b waylandBackend) watchChanges(ctx context.Context, mimeType string, last []byte, ch chan<- []byte) []byte {
+	// wl-paste runs the command on each change, the printed line notifies the change
+	r, w := io.Pipe()
+	cmd := b.command(ctx, "wl-paste", "--watch", "echo")
+	cmd.Stdout = w
+	cmd.WaitDelay = waylandRestartDelay
+	err := cmd.Start()
+	if err != nil {
+		return last
+	}
+	go func() {
+		cmd.Wait()
+		w.Close()
+	}()
+	defer r.Close()
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		data, err := b.Read(mimeType)
+		if err != nil || len(data) == 0 || bytes.Equal(data, last) {
+			continue
+		}
+		last = data
+		select {
+		case ch <- data:
+		case <-ctx.Done():
+			return last
+		}
+	}
+	return last
+}
+
+// command create the wl-clipboard command on the selection of the backend
+func (b waylandBackend) command(ctx context.Context, name string, args ...string) *exec.Cmd {
+	if b.primary {
+		args = append([]string{"--primary"}, args...)
+	}
+	return exec.CommandContext(ctx, name, args...)
+}
+
+// waylandPasteType returns the type of wl-paste by the mime type, the text type matches all text mime types like UTF8_STRING
+func waylandPasteType(mimeType string) (string, error) {
+	switch mimeType {
+	case MimeTextPlain:
+		return "text", nil
+	case MimeImagePNG, MimeTextHTML, MimeURIList, MimeTextRTF:
+		return mimeType, nil
+	}
+	return "", xerror.NewRuntimeErrorf("unsupported clipboard format %s", mimeType)
+}
@@ -0,0 +1,292 @@
+//go:build !windows
+
+package clipboard
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeWlCopy keep the clipboard in files of FAKE_CLIPBOARD_DIR and write the change event,
+// the primary selection is kept in the primary directory
+const fakeWlCopy = `#!/bin/sh
+if [ "$1" = "--primary" ]; then
+	FAKE_CLIPBOARD_DIR="$FAKE_CLIPBOARD_DIR/primary"
+	mkdir -p "$FAKE_CLIPBOARD_DIR"
+	shift
+fi
+[ "$1" = "--type" ] || exit 2
+cat > "$FAKE_CLIPBOARD_DIR/$(echo "$2" | tr / _)"
+echo > "$FAKE_CLIPBOARD_DIR/event"
+`
+
+// fakeWlPaste print the clipboard, or run the command when the change event is written with --watch,
+// it fails with FAKE_WL_PASTE_ERROR if it's set
+const fakeWlPaste = `#!/bin/sh
+if [ "$1" = "--primary" ]; then
+	FAKE_CLIPBOARD_DIR="$FAKE_CLIPBOARD_DIR/primary"
+	mkdir -p "$FAKE_CLIPBOARD_DIR"
+	shift
+fi
+if [ "$1" = "--watch" ]; then
+	shift
+	"$@"
+	while :; do
+		if [ -f "$FAKE_CLIPBOARD_DIR/event" ]; then
+			rm -f "$FAKE_CLIPBOARD_DIR/event"
+			"$@"
+		fi
+		sleep 0.02
+	done
+fi
+[ -n "$FAKE_WL_PASTE_ERROR" ] && { echo "$FAKE_WL_PASTE_ERROR" >&2; exit 1; }
+[ "$1" = "--no-newline" ] && [ "$2" = "--type" ] || exit 2
+type="$3"
+[ "$type" = "text" ] && type="text/plain"
+file="$FAKE_CLIPBOARD_DIR/$(echo "$type" | tr / _)"
+[ -f "$file" ] || { echo "No selection" >&2; exit 1; }
+cat "$file"
+`
+
+// newFakeWaylandBackend create the wayland backend with the fake wl-clipboard commands on PATH
+func newFakeWaylandBackend(t *testing.T) ClipboardBackend {
+	binDir := t.TempDir()
+	for name, script := range map[string]string{"wl-copy": fakeWlCopy, "wl-paste": fakeWlPaste} {
+		err := os.WriteFile(filepath.Join(binDir, name), []byte(script), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	t.Setenv("FAKE_CLIPBOARD_DIR", t.TempDir())
+
+	backend, err := NewWaylandBackend()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestNewWaylandBackendNotInstalled(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	_, err := NewWaylandBackend()
+	if err == nil {
+		t.Error("got no error without wl-clipboard")
+	}
+}
+
+func TestWaylandBackendReadWrite(t *testing.T) {
+	tests := []struct {
+		name       string
+		write      *Format
+		mimeType   string
+		pasteError string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:     "text",
+			write:    &Format{MimeType: MimeTextPlain, Data: []byte("hello\n")},
+			mimeType: MimeTextPlain,
+			want:     "hello\n",
+		},
+		{
+			name:     "image",
+			write:    &Format{MimeType: MimeImagePNG, Data: []byte("\x89PNG")},
+			mimeType: MimeImagePNG,
+			want:     "\x89PNG",
+		},
+		{
+			name:     "html",
+			write:    &Format{MimeType: MimeTextHTML, Data: []byte("<b>hello</b>")},
+			mimeType: MimeTextHTML,
+			want:     "<b>hello</b>",
+		},
+		{
+			name:     "uri list",
+			write:    &Format{MimeType: MimeURIList, Data: []byte("file:///tmp/a.txt\r\n")},
+			mimeType: MimeURIList,
+			want:     "file:///tmp/a.txt\r\n",
+		},
+		{
+			name:     "rtf",
+			write:    &Format{MimeType: MimeTextRTF, Data: []byte(`{\rtf1 hello}`)},
+			mimeType: MimeTextRTF,
+			want:     `{\rtf1 hello}`,
+		},
+		{
+			name:     "empty clipboard",
+			mimeType: MimeTextPlain,
+		},
+		{
+			name:     "format not copied",
+			write:    &Format{MimeType: MimeTextPlain, Data: []byte("hello")},
+			mimeType: MimeTextHTML,
+		},
+		{
+			name:       "no suitable type",
+			mimeType:   MimeTextHTML,
+			pasteError: "No suitable type of content copied",
+		},
+		{
+			name:       "wl-paste failed",
+			mimeType:   MimeTextPlain,
+			pasteError: "Failed to connect to a Wayland server",
+			wantErr:    true,
+		},
+		{
+			name:     "unsupported format",
+			mimeType: MimeFiles,
+			wantErr:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			backend := newFakeWaylandBackend(t)
+			if test.write != nil {
+				err := backend.Write(test.write.MimeType, test.write.Data)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			t.Setenv("FAKE_WL_PASTE_ERROR", test.pasteError)
+			got, err := backend.Read(test.mimeType)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, test.wantErr)
+			}
+			if string(got) != test.want {
+				t.Errorf("got %q, wanted %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestIsEmptySelection(t *testing.T) {
+	tests := []struct {
+		name     string
+		stderr   string
+		messages []string
+		want     bool
+	}{
+		{
+			name:     "xclip target not available",
+			stderr:   "Error: target STRING not available",
+			messages: []string{"not available"},
+			want:     true,
+		},
+		{
+			name:     "xclip can't open display",
+			stderr:   "Error: Can't open display: (null)",
+			messages: []string{"not available"},
+		},
+		{
+			name:     "wl-paste no selection",
+			stderr:   "No selection",
+			messages: waylandEmptyMessages,
+			want:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := exec.Command("sh", "-c", `echo "$0" >&2; exit 1`, test.stderr).Output()
+			if got := isEmptySelection(err, test.messages...); got != test.want {
+				t.Errorf("got %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestWaylandBackendReadOtherFormats(t *testing.T) {
+	backend := newFakeWaylandBackend(t)
+	copied := []Format{
+		{MimeType: MimeTextPlain, Data: []byte("hello")},
+		{MimeType: MimeTextHTML, Data: []byte("<b>hello</b>")},
+	}
+	for _, f := range copied {
+		err := backend.Write(f.MimeType, f.Data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the html copied with the watched text is read through wl-paste
+	formats := readOtherFormats(backend)
+	if len(formats) != 1 || formats[0].MimeType != MimeTextHTML || string(formats[0].Data) != "<b>hello</b>" {
+		t.Errorf("got %v, wanted the html", formats)
+	}
+}
+
+func TestWaylandBackendWatch(t *testing.T) {
+	backend := newFakeWaylandBackend(t)
+	err := backend.Write(MimeTextPlain, []byte("before watch"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := backend.Watch(ctx, MimeTextPlain)
+
+	// the clipboard written before watching is not a change
+	for _, text := range []string{"a", "b"} {
+		err := backend.Write(MimeTextPlain, []byte(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case got := <-changes:
+			if string(got) != text {
+				t.Errorf("got %q, wanted %q", got, text)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for %q", text)
+		}
+	}
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Error("got a change after the watch ended")
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("the watch is not closed")
+	}
+}
+
+func TestWaylandBackendPrimary(t *testing.T) {
+	backend := newFakeWaylandBackend(t)
+	primary, err := PrimaryBackend(backend)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = primary.Write(MimeTextPlain, []byte("selected"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		backend ClipboardBackend
+		want    string
+	}{
+		{name: "clipboard", backend: backend, want: ""},
+		{name: "primary", backend: primary, want: "selected"},
+	}
+	for _, test := range tests {
+		got, err := test.backend.Read(MimeTextPlain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != test.want {
+			t.Errorf("%s got %q, wanted %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// xclipPollInterval the interval to check the selection is changed, xclip can't watch the selection
+const xclipPollInterval = time.Second
+
+// xclipBackend a selection of the X11 clipboard by the xclip command, for the selections other than the clipboard
+// which the system backend can't access
+type xclipBackend struct {
+	selection string
+}
+
+// newXclipBackend create the backend of the X11 selection, returns an error if xclip is not installed
+func newXclipBackend(selection string) (ClipboardBackend, error) {
+	_, err := exec.LookPath("xclip")
+	if err != nil {
+		return nil, xerror.NewRuntimeErrorf("can not find xclip, xclip is required for the %s selection", selection).Wrap(err)
+	}
+	return xclipBackend{selection: selection}, nil
+}
+
+func (xclipBackend) Formats() []string {
+	return []string{MimeTextPlain, MimeImagePNG, MimeTextHTML, MimeURIList, MimeTextRTF}
+}
+
+func (b xclipBackend) Read(mimeType string) ([]byte, error) {
+	target, err := xclipTarget(mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := exec.Command("xclip", "-selection", b.selection, "-out", "-target", target).Output()
+	// the selection is empty or doesn't have the target
+	if isEmptySelection(err, "not available") {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not run xclip").Wrap(err)
+	}
+	return data, nil
+}
+
+func (b xclipBackend) Write(mimeType string, data []byte) error {
+	target, err := xclipTarget(mimeType)
+	if err != nil {
+		return err
+	}
+
+	// xclip serves the selection from a forked process, the output is not captured to not wait for it
+	cmd := exec.Command("xclip", "-selection", b.selection, "-in", "-target", target)
+	cmd.Stdin = bytes.NewReader(data)
+	err = cmd.Run()
+	if err != nil {
+		return xerror.NewRuntimeError("can not run xclip").Wrap(err)
+	}
+	return nil
+}
+
+func (b xclipBackend) Watch(ctx context.Context, mimeType string) <-chan []byte {
+	ch := make(chan []byte)
+	if _, err := xclipTarget(mimeType); err != nil {
+		close(ch)
+		return ch
+	}
+
+	last, _ := b.Read(mimeType)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(xclipPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			data, err := b.Read(mimeType)
+			if err != nil || len(data) == 0 || bytes.Equal(data, last) {
+				continue
+			}
+			last = data
+			select {
+			case ch <- data:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// xclipTarget returns the X11 target by the mime type, the targets other than the text are named by the mime type
+func xclipTarget(mimeType string) (string, error) {
+	switch mimeType {
+	case MimeTextPlain:
+		return "UTF8_STRING", nil
+	case MimeImagePNG, MimeTextHTML, MimeURIList, MimeTextRTF:
+		return mimeType, nil
+	}
+	return "", xerror.NewRuntimeErrorf("unsupported clipboard format %s", mimeType)
+}
@@ -0,0 +1,117 @@
+package compression
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// Algorithm compression algorithm
+type Algorithm string
+
+const (
+	None Algorithm = ""
+	Gzip Algorithm = "gzip"
+	Zstd Algorithm = "zstd"
+)
+
+// Algorithms the supported algorithms in the preferred order
+var Algorithms = []Algorithm{Zstd, Gzip}
+
+// Compress compress the data by the algorithm at the level, level 0 is the default level of the algorithm
+func Compress(algorithm Algorithm, level int, data []byte) ([]byte, error) {
+	switch algorithm {
+	case None:
+		return data, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		var buf bytes.Buffer
+		w, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not create gzip writer").Wrap(err)
+		}
+		_, err = w.Write(data)
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not gzip data").Wrap(err)
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not gzip data").Wrap(err)
+		}
+		return buf.Bytes(), nil
+	case Zstd:
+		encoderLevel := zstd.SpeedDefault
+		if level != 0 {
+			encoderLevel = zstd.EncoderLevelFromZstd(level)
+		}
+		w, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(encoderLevel))
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not create zstd writer").Wrap(err)
+		}
+		defer w.Close()
+		return w.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	default:
+		return nil, xerror.NewRuntimeErrorf("unknown compression algorithm %q", algorithm)
+	}
+}
+
+// Decompress decompress the data by the algorithm, the data larger than maxSize after decompressed is refused
+func Decompress(algorithm Algorithm, data []byte, maxSize int) ([]byte, error) {
+	var r io.Reader
+	switch algorithm {
+	case None:
+		return data, nil
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not create gzip reader").Wrap(err)
+		}
+		defer gr.Close()
+		r = gr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data), zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, xerror.NewRuntimeError("can not create zstd reader").Wrap(err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, xerror.NewRuntimeErrorf("unknown compression algorithm %q", algorithm)
+	}
+
+	// read one more byte to know the data is larger than max size
+	decompressed, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, xerror.NewRuntimeErrorf("can not decompress %s data", algorithm).Wrap(err)
+	}
+	if len(decompressed) > maxSize {
+		return nil, xerror.NewRuntimeErrorf("decompressed data size > max size %d", maxSize)
+	}
+	return decompressed, nil
+}
+
+// compressedMagics the file signatures of the formats already compressed
+var compressedMagics = [][]byte{
+	{0x89, 'P', 'N', 'G', '\r', '\n', 0x1A, '\n'}, // png
+	{0xFF, 0xD8, 0xFF},       // jpeg
+	[]byte("GIF8"),           // gif
+	{'P', 'K', 0x03, 0x04},   // zip
+	{0x1F, 0x8B},             // gzip
+	{0x28, 0xB5, 0x2F, 0xFD}, // zstd
+}
+
+// IsCompressed returns true if the data is a format already compressed, compressing it again saves nothing
+func IsCompressed(data []byte) bool {
+	for _, magic := range compressedMagics {
+		if bytes.HasPrefix(data, magic) {
+			return true
+		}
+	}
+	// webp is a riff container with the webp form type
+	return len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WEBP"))
+}
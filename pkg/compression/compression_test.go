@@ -0,0 +1,99 @@
+package compression
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCompressRoundTrip(t *testing.T) {
+	text := bytes.Repeat([]byte("cross clipboard "), 1000)
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+		level     int
+	}{
+		{name: "none", algorithm: None},
+		{name: "gzip default level", algorithm: Gzip},
+		{name: "gzip level 9", algorithm: Gzip, level: 9},
+		{name: "zstd default level", algorithm: Zstd},
+		{name: "zstd level 19", algorithm: Zstd, level: 19},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := Compress(tt.algorithm, tt.level, text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.algorithm != None && len(compressed) >= len(text) {
+				t.Errorf("compressed size %d >= data size %d", len(compressed), len(text))
+			}
+
+			decompressed, err := Decompress(tt.algorithm, compressed, len(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decompressed, text) {
+				t.Error("decompressed data is not the same as the data")
+			}
+		})
+	}
+}
+
+func TestDecompressMaxSize(t *testing.T) {
+	data := make([]byte, 1<<20)
+	for _, algorithm := range Algorithms {
+		t.Run(string(algorithm), func(t *testing.T) {
+			compressed, err := Compress(algorithm, 0, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = Decompress(algorithm, compressed, len(data)-1)
+			if err == nil {
+				t.Error("expected an error decompressing data larger than the max size")
+			}
+		})
+	}
+}
+
+func TestDecompressInvalid(t *testing.T) {
+	tests := []struct {
+		name      string
+		algorithm Algorithm
+	}{
+		{name: "gzip", algorithm: Gzip},
+		{name: "zstd", algorithm: Zstd},
+		{name: "unknown", algorithm: Algorithm("lz4")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decompress(tt.algorithm, []byte("not compressed"), 1024)
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestIsCompressed(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "png", data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), want: true},
+		{name: "jpeg", data: []byte("\xff\xd8\xff\xe0\x00\x10JFIF"), want: true},
+		{name: "gif", data: []byte("GIF89a"), want: true},
+		{name: "webp", data: []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), want: true},
+		{name: "wav", data: []byte("RIFF\x24\x00\x00\x00WAVEfmt "), want: false},
+		{name: "zip", data: []byte("PK\x03\x04\x14\x00"), want: true},
+		{name: "text", data: []byte("hello world"), want: false},
+		{name: "empty", data: []byte{}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsCompressed(tt.data); got != tt.want {
+				t.Errorf("IsCompressed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
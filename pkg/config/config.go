@@ -5,6 +5,8 @@ import (
 	"github.com/yqs112358/cross-clipboard/pkg/utils/maputil"
 	"os"
 	"os/user"
+	"path/filepath"
+	"reflect"
 
 	gopenpgp "github.com/ProtonMail/gopenpgp/v2/crypto"
 	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
@@ -21,10 +23,19 @@ type Config struct {
 	// Network Config
 	GroupName string          `mapstructure:"group_name"`
 	Discovery DiscoveryConfig `mapstructure:"discovery"`
+	Network   NetworkConfig   `mapstructure:"network"`
 
 	// Clipbaord Config
-	MaxSize    int `mapstructure:"max_size"`    // limit clipboard size (bytes) to send
-	MaxHistory int `mapstructure:"max_history"` // limit number of clipboard history
+	Clipboard  ClipboardConfig `mapstructure:"clipboard"`
+	MaxSize    int             `mapstructure:"max_size"`    // limit clipboard size (bytes) to send
+	MaxHistory int             `mapstructure:"max_history"` // limit number of clipboard history
+	MaxQueue   int             `mapstructure:"max_queue"`   // limit number of clipboards waiting to send to each device
+
+	Transfer  TransferConfig  `mapstructure:"transfer"`
+	Heartbeat HeartbeatConfig `mapstructure:"heartbeat"`
+
+	Compression CompressionConfig `mapstructure:"compression"`
+	Files       FilesConfig       `mapstructure:"files"`
 
 	// Device Config
 	Username             string            `mapstructure:"-"`           // username of the device
@@ -38,23 +49,132 @@ type Config struct {
 	ConfigDirPath string // config directory path
 }
 
-// DiscoveryConfig is the config of Discoverers
+// DiscoveryConfig is the config of Discoverers, each field is the config of the discoverer registered by the same name
 type DiscoveryConfig struct {
 	MDNS         MDNSConfig         `mapstructure:"mdns"`
 	UDPBroadcast UDPBroadcastConfig `mapstructure:"udp_broadcast"`
+	Static       StaticConfig       `mapstructure:"static"`
+	Rendezvous   RendezvousConfig   `mapstructure:"rendezvous"`
+
+	StaticPeers []string `mapstructure:"static_peers"` // full multiaddrs of peers to dial, e.g. /ip4/10.1.2.3/tcp/4001/p2p/<id>
 }
 
 type MDNSConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
 	ListenHost string `mapstructure:"listen_host"`
 	ListenPort int    `mapstructure:"listen_port"`
 }
 
 type UDPBroadcastConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
 	ListenHost string `mapstructure:"listen_host"`
 	ListenPort int    `mapstructure:"listen_port"`
 	Interval   int    `mapstructure:"discovery_interval"`
 }
 
+type StaticConfig struct {
+	Enabled        bool `mapstructure:"enabled"`
+	RedialInterval int  `mapstructure:"redial_interval"` // seconds between redialing offline static peers
+}
+
+// RendezvousConfig is the config of finding the devices of the group through a private dht
+type RendezvousConfig struct {
+	Enabled        bool                   `mapstructure:"enabled"`
+	BootstrapPeers []string               `mapstructure:"bootstrap_peers"` // full multiaddrs of the rendezvous servers
+	Interval       int                    `mapstructure:"discovery_interval"`
+	Server         RendezvousServerConfig `mapstructure:"server"` // config of this device running in rendezvous mode
+}
+
+type RendezvousServerConfig struct {
+	ListenHost string `mapstructure:"listen_host"`
+	ListenPort int    `mapstructure:"listen_port"`
+}
+
+// NetworkConfig is the config of NAT traversal and circuit relay for devices outside the LAN
+type NetworkConfig struct {
+	NATPortMap   bool        `mapstructure:"nat_port_map"`  // map the listen port on the router by UPnP or NAT-PMP
+	HolePunching bool        `mapstructure:"hole_punching"` // upgrade relayed connections to direct connections
+	RelayClient  bool        `mapstructure:"relay_client"`  // reserve a slot on the static relays to be reachable through them
+	StaticRelays []string    `mapstructure:"static_relays"` // full multiaddrs of the relays, e.g. /ip4/1.2.3.4/tcp/4003/p2p/<id>
+	PSK          string      `mapstructure:"psk"`           // hex pre-shared key of the group private network, empty to disable
+	Relay        RelayConfig `mapstructure:"relay"`         // config of this device running in relay mode
+}
+
+type RelayConfig struct {
+	ListenHost      string   `mapstructure:"listen_host"`
+	ListenPort      int      `mapstructure:"listen_port"`
+	MaxReservations int      `mapstructure:"max_reservations"` // max devices reachable through the relay
+	LimitDuration   int      `mapstructure:"limit_duration"`   // seconds a relayed connection is kept before it's reset
+	LimitData       int64    `mapstructure:"limit_data"`       // bytes relayed in each direction before the connection is reset
+	AllowedPeers    []string `mapstructure:"allowed_peers"`    // peer ids allowed to use the relay when there is no psk
+}
+
+// ClipboardConfig is the config of the os clipboard
+type ClipboardConfig struct {
+	Backend string `mapstructure:"backend"` // auto, system, wayland or memory for the devices without a display
+	Primary string `mapstructure:"primary"` // sync the primary selection: off, separate or merged with the clipboard
+}
+
+// TransferConfig is the config of the clipboard sent in chunks
+type TransferConfig struct {
+	ResumeExpiry int `mapstructure:"resume_expiry"` // seconds to keep a partial transfer to resume after reconnecting
+}
+
+// HeartbeatConfig is the config of checking the connected devices are alive
+type HeartbeatConfig struct {
+	Interval int `mapstructure:"interval"` // seconds between pings
+	Timeout  int `mapstructure:"timeout"`  // seconds without any message to disconnect the device
+}
+
+// CompressionConfig is the config of compressing the clipboard before it's encrypted
+type CompressionConfig struct {
+	Enabled bool `mapstructure:"enabled"`  // compress the clipboard sent to the devices supporting it
+	Level   int  `mapstructure:"level"`    // compression level of the algorithm, 0 is the default level
+	MinSize int  `mapstructure:"min_size"` // clipboard smaller than it (bytes) is not compressed
+}
+
+// FilesConfig is the config of the files and directories copied to the clipboard
+type FilesConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`         // send the copied files and receive the files from the devices
+	DownloadDir    string   `mapstructure:"download_dir"`    // directory to put the received files
+	MaxSize        int      `mapstructure:"max_size"`        // limit total size (bytes) of the files copied together
+	AllowedDevices []string `mapstructure:"allowed_devices"` // peer ids of the devices allowed to send files, empty to allow all trusted devices
+}
+
+// IsAllowed returns true if the device of the peer id is allowed to send files,
+// the name is chosen by the device so it's not matched
+func (f FilesConfig) IsAllowed(peerID string) bool {
+	if !f.Enabled {
+		return false
+	}
+	if len(f.AllowedDevices) == 0 {
+		return true
+	}
+	for _, allowed := range f.AllowedDevices {
+		if allowed == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEnabled returns true if `discovery.<name>.enabled` is true, unknown discoverer is disabled
+func (d DiscoveryConfig) IsEnabled(name string) bool {
+	v := reflect.ValueOf(d)
+	typ := v.Type()
+	for i := 0; i < v.NumField(); i++ {
+		if typ.Field(i).Tag.Get("mapstructure") != name {
+			continue
+		}
+		if v.Field(i).Kind() != reflect.Struct {
+			return false
+		}
+		enabled := v.Field(i).FieldByName("Enabled")
+		return enabled.IsValid() && enabled.Kind() == reflect.Bool && enabled.Bool()
+	}
+	return false
+}
+
 func LoadConfig(configDir string) (*Config, error) {
 	thisUser, err := user.Current()
 	if err != nil {
@@ -72,14 +192,49 @@ func LoadConfig(configDir string) (*Config, error) {
 	viper.AddConfigPath(configDir)
 
 	viper.SetDefault("group_name", "default")
+	viper.SetDefault("discovery.mdns.enabled", true)
 	viper.SetDefault("discovery.mdns.listen_host", "0.0.0.0")
 	viper.SetDefault("discovery.mdns.listen_port", 4001)
+	viper.SetDefault("discovery.udp_broadcast.enabled", true)
 	viper.SetDefault("discovery.udp_broadcast.listen_host", "0.0.0.0")
 	viper.SetDefault("discovery.udp_broadcast.listen_port", 4002)
 	viper.SetDefault("discovery.udp_broadcast.discovery_interval", 2)
+	viper.SetDefault("discovery.static.enabled", true)
+	viper.SetDefault("discovery.static.redial_interval", 30)
+	viper.SetDefault("discovery.static_peers", []string{})
+	viper.SetDefault("discovery.rendezvous.enabled", false)
+	viper.SetDefault("discovery.rendezvous.bootstrap_peers", []string{})
+	viper.SetDefault("discovery.rendezvous.discovery_interval", 60)
+	viper.SetDefault("discovery.rendezvous.server.listen_host", "0.0.0.0")
+	viper.SetDefault("discovery.rendezvous.server.listen_port", 4004)
+
+	viper.SetDefault("network.nat_port_map", false)
+	viper.SetDefault("network.hole_punching", false)
+	viper.SetDefault("network.relay_client", false)
+	viper.SetDefault("network.static_relays", []string{})
+	viper.SetDefault("network.psk", "")
+	viper.SetDefault("network.relay.listen_host", "0.0.0.0")
+	viper.SetDefault("network.relay.listen_port", 4003)
+	viper.SetDefault("network.relay.max_reservations", 32)
+	viper.SetDefault("network.relay.limit_duration", 3600)
+	viper.SetDefault("network.relay.limit_data", 256<<20) // 256MB
+	viper.SetDefault("network.relay.allowed_peers", []string{})
 
+	viper.SetDefault("clipboard.backend", "auto")
+	viper.SetDefault("clipboard.primary", "off")
 	viper.SetDefault("max_size", 5<<20) // 5MB
 	viper.SetDefault("max_history", 10)
+	viper.SetDefault("max_queue", 4)
+	viper.SetDefault("transfer.resume_expiry", 600)
+	viper.SetDefault("heartbeat.interval", 10)
+	viper.SetDefault("heartbeat.timeout", 30)
+	viper.SetDefault("compression.enabled", true)
+	viper.SetDefault("compression.level", 3)
+	viper.SetDefault("compression.min_size", 512)
+	viper.SetDefault("files.enabled", false)
+	viper.SetDefault("files.download_dir", filepath.Join(thisUser.HomeDir, "Downloads", "cross-clipboard"))
+	viper.SetDefault("files.max_size", 50<<20) // 50MB
+	viper.SetDefault("files.allowed_devices", []string{})
 
 	viper.SetDefault("hidden_text", true)
 
@@ -160,6 +315,21 @@ func (c *Config) Save() error {
 	return nil
 }
 
+// SetPSK set the pre-shared key of the group private network and save it to the config file
+func (c *Config) SetPSK(psk string) error {
+	c.Network.PSK = psk
+	viper.Set("network.psk", psk)
+
+	err := viper.WriteConfig()
+	if err != nil {
+		return xerror.NewRuntimeError(fmt.Sprintf(
+			"failed to write config at path %s",
+			viper.ConfigFileUsed(),
+		)).Wrap(err)
+	}
+	return nil
+}
+
 // Clean all configs
 func (c *Config) ResetToDefault() error {
 	err := os.RemoveAll(c.ConfigDirPath)
@@ -0,0 +1,49 @@
+package crossclipboard
+
+import (
+	"math/rand"
+	"time"
+)
+
+// backoffJitter the maximum random fraction added or removed from the backoff delay
+const backoffJitter = 0.2
+
+// backoff exponential backoff with jitter for redialing a device
+type backoff struct {
+	min time.Duration
+	max time.Duration
+
+	attempt int
+	next    time.Time
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max}
+}
+
+// Ready returns true if the next attempt is allowed at the time
+func (b *backoff) Ready(now time.Time) bool {
+	return !now.Before(b.next)
+}
+
+// Attempt record an attempt at the time and schedule the next attempt
+func (b *backoff) Attempt(now time.Time) {
+	b.next = now.Add(b.Delay())
+	b.attempt++
+}
+
+// Reset reset the backoff after a successful connection
+func (b *backoff) Reset() {
+	b.attempt = 0
+	b.next = time.Time{}
+}
+
+// Delay returns the delay before the next attempt, min * 2^attempt capped by max with jitter
+func (b *backoff) Delay() time.Duration {
+	delay := b.max
+	if b.attempt < 32 && b.min<<b.attempt < b.max && b.min<<b.attempt > 0 {
+		delay = b.min << b.attempt
+	}
+	jitter := (rand.Float64()*2 - 1) * backoffJitter
+	return time.Duration(float64(delay) * (1 + jitter))
+}
@@ -0,0 +1,71 @@
+package crossclipboard
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt int
+		want    time.Duration
+	}{
+		{
+			name:    "first attempt",
+			attempt: 0,
+			want:    time.Second,
+		},
+		{
+			name:    "exponential",
+			attempt: 3,
+			want:    8 * time.Second,
+		},
+		{
+			name:    "capped by max",
+			attempt: 10,
+			want:    time.Minute,
+		},
+		{
+			name:    "avoid overflow",
+			attempt: 100,
+			want:    time.Minute,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := newBackoff(time.Second, time.Minute)
+			b.attempt = test.attempt
+
+			got := b.Delay()
+			low := time.Duration(float64(test.want) * (1 - backoffJitter))
+			high := time.Duration(float64(test.want) * (1 + backoffJitter))
+			if got < low || got > high {
+				t.Fatalf("got %s, want %s ~ %s", got, low, high)
+			}
+		})
+	}
+}
+
+func TestBackoffReady(t *testing.T) {
+	now := time.Now()
+	b := newBackoff(time.Second, time.Minute)
+
+	if !b.Ready(now) {
+		t.Fatal("new backoff should be ready")
+	}
+
+	b.Attempt(now)
+	if b.Ready(now) {
+		t.Fatal("backoff should not be ready right after an attempt")
+	}
+	if !b.Ready(now.Add(2 * time.Second)) {
+		t.Fatal("backoff should be ready after the delay")
+	}
+
+	b.Reset()
+	if !b.Ready(now) {
+		t.Fatal("backoff should be ready after reset")
+	}
+}
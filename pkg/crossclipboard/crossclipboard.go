@@ -1,22 +1,26 @@
 package crossclipboard
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"log"
+	"os"
 	"time"
 
-	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/host"
-	"github.com/multiformats/go-multiaddr"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
 	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 	"github.com/yqs112358/cross-clipboard/pkg/crypto"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 	"github.com/yqs112358/cross-clipboard/pkg/devicemanager"
 	"github.com/yqs112358/cross-clipboard/pkg/discovery"
+	"github.com/yqs112358/cross-clipboard/pkg/invite"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+	"github.com/yqs112358/cross-clipboard/pkg/replay"
 	"github.com/yqs112358/cross-clipboard/pkg/stream"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 )
@@ -29,15 +33,21 @@ type CrossClipboard struct {
 	ClipboardManager *clipboard.ClipboardManager
 	DeviceManager    *devicemanager.DeviceManager
 
-	streamHandler *stream.StreamHandler
-	NewPeerChan   chan peer.AddrInfo
+	streamHandler    *stream.StreamHandler
+	discoveryManager *discovery.Manager
+	reconnector      *reconnector
+	NewPeerChan      chan peer.AddrInfo
 
 	LogChan   chan string
 	ErrorChan chan error
 
 	stopDiscovery chan struct{}
+	cancel        context.CancelFunc
 }
 
+// connectTimeout the timeout to connect a peer once, failed trusted devices are redialed with backoff
+const connectTimeout = 15 * time.Second
+
 // NewCrossClipboard initial cross clipbaord
 func NewCrossClipboard(cfg *config.Config) (*CrossClipboard, error) {
 	cc := &CrossClipboard{
@@ -48,34 +58,51 @@ func NewCrossClipboard(cfg *config.Config) (*CrossClipboard, error) {
 		stopDiscovery: make(chan struct{}),
 	}
 
-	cc.ClipboardManager = clipboard.NewClipboardManager(cc.Config)
+	backend, backendErr := newClipboardBackend(cc.Config.Clipboard.Backend)
+	if backend == nil {
+		return nil, backendErr
+	}
+	primary, primaryErr := newPrimaryBackend(cc.Config.Clipboard.Primary, backend)
+	var fatalErr *xerror.FatalError
+	if errors.As(primaryErr, &fatalErr) {
+		return nil, primaryErr
+	}
+	cc.ClipboardManager = clipboard.NewClipboardManager(cc.Config, backend, primary)
 	cc.DeviceManager = devicemanager.NewDeviceManager(cc.Config)
 
-	ctx := context.Background()
+	ctx, cancel := context.WithCancel(context.Background())
+	cc.cancel = cancel
 
-	// 0.0.0.0 will listen on any interface device.
-	// TODO: change bad logic
-	sourceMultiAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", cc.Config.Discovery.MDNS.ListenHost, cc.Config.Discovery.MDNS.ListenHost))
+	// TODO: change bad logic, the host listen address is in mdns config
+	host, err := p2p.NewHost(cc.Config, cc.Config.Discovery.MDNS.ListenHost, cc.Config.Discovery.MDNS.ListenPort)
 	if err != nil {
-		return nil, xerror.NewFatalError("error to multiaddr.NewMultiaddr").Wrap(err)
-	}
-
-	// libp2p.New constructs a new libp2p Host.
-	host, err := libp2p.New(
-		libp2p.ListenAddrs(sourceMultiAddr),
-		libp2p.Identity(cc.Config.ID),
-	)
-	if err != nil {
-		return nil, xerror.NewFatalError("error to libp2p.New").Wrap(err)
+		return nil, err
 	}
 	cc.Host = host
+	cc.reconnector = newReconnector(cc)
 
 	pgpDecrypter, err := crypto.NewPGPDecrypter(cfg.PGPPrivateKey)
 	if err != nil {
 		return nil, xerror.NewFatalError("error to crypto.NewPGPDecrypter").Wrap(err)
 	}
 
+	inviteStore, err := invite.NewStore(cfg.ConfigDirPath)
+	if err != nil {
+		return nil, xerror.NewFatalError("error to invite.NewStore").Wrap(err)
+	}
+
+	replayFilter, err := replay.NewFilter(cfg.ConfigDirPath)
+	if err != nil {
+		return nil, xerror.NewFatalError("error to replay.NewFilter").Wrap(err)
+	}
+
 	go func() {
+		for _, err := range []error{backendErr, primaryErr} {
+			if err != nil {
+				cc.ErrorChan <- err
+			}
+		}
+
 		err := cc.DeviceManager.Load()
 		if err != nil {
 			cc.ErrorChan <- xerror.NewFatalError("can not load device from setting").Wrap(err)
@@ -88,26 +115,49 @@ func NewCrossClipboard(cfg *config.Config) (*CrossClipboard, error) {
 			cc.LogChan,
 			cc.ErrorChan,
 			pgpDecrypter,
+			inviteStore,
+			replayFilter,
+			audit.NewLogger(cfg.ConfigDirPath),
 		)
 		cc.streamHandler = streamHandler
 
 		// This function is called when a peer initiates a connection and starts a stream with this peer.
-		cc.Host.SetStreamHandler(stream.PROTOCAL_ID, streamHandler.HandleStream)
+		cc.Host.SetStreamHandler(stream.CONTROL_PROTOCAL_ID, streamHandler.HandleStream)
+		cc.Host.SetStreamHandler(stream.DATA_PROTOCAL_ID, streamHandler.HandleDataStream)
+		cc.Host.SetStreamHandler(stream.LEGACY_PROTOCAL_ID, streamHandler.HandleLegacyStream)
 		cc.LogChan <- fmt.Sprintf("[*] Your PeerID is: %s", host.ID().String())
 
-		cc.startDiscoverers()
+		cc.startDiscoverers(ctx)
+		go cc.reconnector.run(ctx)
 		cc.discoveryLoop(ctx)
 	}()
 
 	return cc, nil
 }
 
-func (cc *CrossClipboard) startDiscoverers() {
-	mdnsDiscoverer := discovery.NewMdnsDiscoverer(cc.Config)
-	err := mdnsDiscoverer.Init(cc.Host, cc.Config.GroupName, cc.NewPeerChan, cc.LogChan)
+// startDiscoverers start all discoverers enabled in config, the discovered peers are sent to NewPeerChan
+func (cc *CrossClipboard) startDiscoverers(ctx context.Context) {
+	cc.discoveryManager = discovery.NewManager(cc.Config)
+	err := cc.discoveryManager.Start(ctx, cc.Host, cc.NewPeerChan, cc.LogChan)
 	if err != nil {
-		cc.ErrorChan <- xerror.NewFatalError("error to discovery.InitMultiMDNS").Wrap(err)
+		cc.ErrorChan <- xerror.NewRuntimeError("error to start discoverers").Wrap(err)
+	}
+}
+
+// ConnectPeer dial a peer by a full multiaddr with `/p2p/<id>`, the peer is connected by the discovery loop
+func (cc *CrossClipboard) ConnectPeer(addr string) error {
+	peers, err := p2p.ParseAddrInfos([]string{addr})
+	if err != nil {
+		return err
+	}
+	if peers[0].ID == cc.Host.ID() {
+		return xerror.NewRuntimeError("can not connect to this host")
 	}
+
+	go func() {
+		cc.NewPeerChan <- peers[0]
+	}()
+	return nil
 }
 
 func (cc *CrossClipboard) discoveryLoop(ctx context.Context) {
@@ -120,44 +170,61 @@ func (cc *CrossClipboard) discoveryLoop(ctx context.Context) {
 				continue
 			}
 
+			// skip the peer discovered again or redialed while its control stream is alive, even if it's not paired yet
+			if dv != nil && dv.HasLiveControlStream() {
+				continue
+			}
+
 			cc.LogChan <- fmt.Sprintf("connecting to peer: %s", peerInfo.ID.Loggable())
 
-			retry := 1
-			for ; retry < 5; retry++ { // retry to connect
-				if err := cc.Host.Connect(ctx, peerInfo); err != nil {
-					cc.ErrorChan <- xerror.NewRuntimeErrorf(
-						"error to connect to peer %s, retrying %d",
-						peerInfo.ID.Loggable(),
-						retry,
-					).Wrap(err)
-					time.Sleep(time.Duration(retry*10) * time.Second)
-					continue
-				}
-				break
-			}
-			if retry == 5 {
-				cc.ErrorChan <- xerror.NewRuntimeErrorf("error to connect to peer %s", peerInfo.ID.Loggable())
+			connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+			err := cc.Host.Connect(connectCtx, peerInfo)
+			cancel()
+			if err != nil {
+				// trusted devices are redialed by the reconnector with backoff, others when discovered again
+				cc.ErrorChan <- xerror.NewRuntimeErrorf("error to connect to peer %s", peerInfo.ID.Loggable()).Wrap(err)
 				continue
 			}
 
-			// open a stream, this stream will be handled by handleStream other end
-			stream, err := cc.Host.NewStream(ctx, peerInfo.ID, stream.PROTOCAL_ID)
+			// open the control stream, this stream will be handled by handleStream other end
+			// the connection may be relayed by a relay with limits
+			streamCtx := network.WithAllowLimitedConn(ctx, "cross-clipboard")
+			controlStream, err := cc.Host.NewStream(streamCtx, peerInfo.ID, stream.CONTROL_PROTOCAL_ID)
 			if err != nil {
+				if cc.isLegacyPeer(peerInfo.ID) {
+					cc.ErrorChan <- xerror.NewRuntimeErrorf("peer %s uses an old protocol, the device must be upgraded", peerInfo.ID).Wrap(err)
+					continue
+				}
 				cc.ErrorChan <- xerror.NewRuntimeError("new stream error").Wrap(err)
 				continue
 			}
 
+			// the peer may have connected this host while dialing
+			dv = cc.DeviceManager.GetDevice(peerInfo.ID.String())
 			if dv == nil {
-				dv = device.NewDevice(peerInfo, stream)
+				dv = device.NewDevice(peerInfo, controlStream)
 			} else {
+				if !dv.ReplaceControlStream(controlStream) {
+					// both devices dialed each other, the stream opened by the peer is kept
+					cc.LogChan <- fmt.Sprintf("peer %s dialed this host at the same time, keep the stream of the peer", peerInfo.ID.Loggable())
+					controlStream.Reset()
+					continue
+				}
 				dv.AddressInfo = peerInfo
-				dv.Stream = stream
-				dv.Reader = bufio.NewReader(stream)
-				dv.Writer = bufio.NewWriter(stream)
 			}
 
+			dv.UpdateAddressBook(cc.Host.Peerstore().Addrs(peerInfo.ID))
 			cc.DeviceManager.UpdateDevice(dv)
-			go cc.streamHandler.CreateReadData(dv.Reader, dv)
+			go cc.streamHandler.CreateReadData(dv.ControlStream.Reader, dv)
+
+			// open the data stream on the same connection, so the clipboard payloads don't delay the signals
+			dataStream, err := cc.Host.NewStream(streamCtx, peerInfo.ID, stream.DATA_PROTOCAL_ID)
+			if err != nil {
+				// the clipboard is sent on the control stream instead
+				cc.ErrorChan <- xerror.NewRuntimeErrorf("can not open data stream to peer %s", peerInfo.ID.Loggable()).Wrap(err)
+			} else {
+				cc.streamHandler.AttachDataStream(dv, dataStream)
+			}
 
 			cc.LogChan <- fmt.Sprintf("connected to peer host: %s", peerInfo)
 		case <-cc.stopDiscovery: // when stop discovery
@@ -167,11 +234,115 @@ func (cc *CrossClipboard) discoveryLoop(ctx context.Context) {
 	}
 }
 
+// isLegacyPeer returns true if the peer only supports the protocol before the versioned frame
+func (cc *CrossClipboard) isLegacyPeer(id peer.ID) bool {
+	supported, err := cc.Host.Peerstore().SupportsProtocols(id, stream.CONTROL_PROTOCAL_ID, stream.LEGACY_PROTOCAL_ID)
+	if err != nil {
+		return false
+	}
+	return len(supported) == 1 && supported[0] == stream.LEGACY_PROTOCAL_ID
+}
+
+// TrustDevice trust the device after the user confirmed the pairing code
+func (cc *CrossClipboard) TrustDevice(dv *device.Device) error {
+	if cc.streamHandler == nil {
+		return xerror.NewRuntimeError("stream handler is not ready")
+	}
+	err := cc.streamHandler.TrustDevice(dv)
+	if err != nil {
+		return err
+	}
+	cc.DeviceManager.UpdateDevice(dv)
+	return nil
+}
+
+// QueueDepth returns the number of clipboards waiting to be sent to the device
+func (cc *CrossClipboard) QueueDepth(dv *device.Device) int {
+	if cc.streamHandler == nil {
+		return 0
+	}
+	return cc.streamHandler.QueueDepth(dv)
+}
+
+// BlockDevice block the device
+func (cc *CrossClipboard) BlockDevice(dv *device.Device) {
+	dv.Block()
+	cc.DeviceManager.UpdateDevice(dv)
+}
+
+// PairingCode returns the short authentication string of the pairing with the device,
+// it's the same on both devices when they are trusting the right device
+func (cc *CrossClipboard) PairingCode(dv *device.Device) (crypto.ShortAuthString, error) {
+	fingerprint, err := dv.Fingerprint()
+	if err != nil {
+		return crypto.ShortAuthString{}, err
+	}
+	return crypto.NewShortAuthString(
+		cc.Host.ID().String(),
+		cc.Config.PGPPrivateKey.GetFingerprint(),
+		dv.AddressInfo.ID.String(),
+		fingerprint,
+	), nil
+}
+
+// CreateInvite issue an invite token for a new device, the token is valid for ttl and can be redeemed by one device
+func (cc *CrossClipboard) CreateInvite(ttl time.Duration) (string, error) {
+	addrs := []string{}
+	for _, addr := range p2p.FullAddrs(cc.Host) {
+		addrs = append(addrs, addr.String())
+	}
+
+	token, err := invite.NewToken(cc.Config.ID, cc.Config.GroupName, addrs, ttl)
+	if err != nil {
+		return "", err
+	}
+	return token.Encode()
+}
+
+// JoinGroup join the group by an invite token, the token is presented to the issuing device
+// which vouches for this device to the other group members
+func (cc *CrossClipboard) JoinGroup(encoded string) error {
+	if cc.streamHandler == nil {
+		return xerror.NewRuntimeError("stream handler is not ready")
+	}
+
+	token, err := invite.DecodeToken(encoded)
+	if err != nil {
+		return err
+	}
+	err = token.Verify(cc.Config.GroupName, time.Now())
+	if err != nil {
+		return err
+	}
+
+	peers, err := p2p.ParseAddrInfos(token.Addrs)
+	if err != nil {
+		return err
+	}
+
+	cc.streamHandler.SetJoinToken(token, encoded)
+
+	// present the token to the issuer if it's connected waiting for pairing and ask for its device data again
+	for _, dv := range cc.DeviceManager.ListDevices() {
+		if dv.AddressInfo.ID.String() == token.Issuer && dv.Status == device.StatusPending && !dv.IsTrusted() {
+			cc.streamHandler.SendDeviceData(dv)
+			cc.streamHandler.SendSignal(dv, stream.SignalRequestDeviceData)
+		}
+	}
+
+	go func() {
+		for _, peerInfo := range peers {
+			cc.NewPeerChan <- peerInfo
+		}
+	}()
+	return nil
+}
+
 func (cc *CrossClipboard) Stop() error {
 	if cc.streamHandler != nil {
-		for id, dv := range cc.DeviceManager.Devices {
+		for _, dv := range cc.DeviceManager.ListDevices() {
 			if dv.Status == device.StatusConnected {
-				log.Printf("sending disconneced signal to peer %s \n", id)
+				log.Printf("sending disconneced signal to peer %s \n", dv.AddressInfo.ID)
 				cc.streamHandler.SendSignal(dv, stream.SignalDisconnect)
 			}
 		}
@@ -179,15 +350,30 @@ func (cc *CrossClipboard) Stop() error {
 		// sleep to wait sending disconnect signal
 		time.Sleep(time.Second)
 
-		for id, dv := range cc.DeviceManager.Devices {
+		for _, dv := range cc.DeviceManager.ListDevices() {
 			if dv.Status == device.StatusConnected {
-				log.Printf("ending stream for peer %s \n", id)
-				dv.Stream.Close()
+				log.Printf("ending stream for peer %s \n", dv.AddressInfo.ID)
+				dv.CloseStreams()
+				dv.UpdateAddressBook(cc.Host.Peerstore().Addrs(dv.AddressInfo.ID))
 			}
 		}
+
+		// save the address book to reconnect after restart
+		err := cc.DeviceManager.Save()
+		if err != nil {
+			log.Println(xerror.NewRuntimeError("error to save devices").Wrap(err))
+		}
 	}
 
-	cc.stopDiscovery <- struct{}{}
+	if cc.discoveryManager != nil {
+		err := cc.discoveryManager.Stop()
+		if err != nil {
+			log.Println(xerror.NewRuntimeError("error to stop discoverers").Wrap(err))
+		}
+	}
+	// close instead of send, the discovery loop may be blocked while stopping
+	close(cc.stopDiscovery)
+	cc.cancel()
 
 	err := cc.Host.Close()
 	if err != nil {
@@ -196,3 +382,53 @@ func (cc *CrossClipboard) Stop() error {
 
 	return nil
 }
+
+// newClipboardBackend create the clipboard backend of the config, the auto backend returns the fallback backend
+// with the error of the preferred one
+func newClipboardBackend(name string) (clipboard.ClipboardBackend, error) {
+	if name != "" && name != clipboard.BackendAuto {
+		backend, err := clipboard.NewBackend(name)
+		if err != nil {
+			return nil, xerror.NewFatalErrorf("can not use clipboard backend %s", name).Wrap(err)
+		}
+		return backend, nil
+	}
+
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		backend, err := clipboard.NewWaylandBackend()
+		if err == nil {
+			return backend, nil
+		}
+		// XWayland may still provide the system clipboard
+		fallbackErr := xerror.NewRuntimeError("wayland clipboard is not available, using the system clipboard").Wrap(err)
+		backend, err = clipboard.NewSystemBackend()
+		if err != nil {
+			return clipboard.NewMemoryBackend(), xerror.NewRuntimeError("wayland and system clipboards are not available, using the memory clipboard").Wrap(err)
+		}
+		return backend, fallbackErr
+	}
+
+	backend, err := clipboard.NewSystemBackend()
+	if err != nil {
+		return clipboard.NewMemoryBackend(), xerror.NewRuntimeError("system clipboard is not available, using the memory clipboard").Wrap(err)
+	}
+	return backend, nil
+}
+
+// newPrimaryBackend returns the backend of the primary selection if it's synced by the config,
+// the primary selection is not synced with the error if the clipboard backend doesn't have it
+func newPrimaryBackend(mode string, backend clipboard.ClipboardBackend) (clipboard.ClipboardBackend, error) {
+	switch mode {
+	case "", clipboard.PrimaryOff:
+		return nil, nil
+	case clipboard.PrimarySeparate, clipboard.PrimaryMerged:
+	default:
+		return nil, xerror.NewFatalErrorf("unknown clipboard primary mode %q", mode)
+	}
+
+	primary, err := clipboard.PrimaryBackend(backend)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not sync the primary selection").Wrap(err)
+	}
+	return primary, nil
+}
@@ -0,0 +1,141 @@
+package crossclipboard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+)
+
+const (
+	reconnectCheckInterval = time.Second
+	reconnectMinBackoff    = 2 * time.Second
+	reconnectMaxBackoff    = 5 * time.Minute
+)
+
+// reconnector supervise the trusted devices and redial the disconnected ones with exponential backoff
+type reconnector struct {
+	cc *CrossClipboard
+
+	mu               sync.Mutex
+	backoffs         map[peer.ID]*backoff
+	disconnectedChan chan peer.ID
+}
+
+func newReconnector(cc *CrossClipboard) *reconnector {
+	r := &reconnector{
+		cc:               cc,
+		backoffs:         make(map[peer.ID]*backoff),
+		disconnectedChan: make(chan peer.ID, 16),
+	}
+
+	// the notifee must not block the swarm, the disconnected peers are handled by the run loop
+	cc.Host.Network().Notify(&network.NotifyBundle{
+		DisconnectedF: func(n network.Network, conn network.Conn) {
+			select {
+			case r.disconnectedChan <- conn.RemotePeer():
+			default:
+			}
+		},
+	})
+
+	return r
+}
+
+// run check the devices every interval until the context is done
+func (r *reconnector) run(ctx context.Context) {
+	ticker := time.NewTicker(reconnectCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case id := <-r.disconnectedChan:
+			r.recordDisconnected(id)
+		case <-ticker.C:
+			r.redialDevices(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// recordDisconnected save the last known addresses and last seen time of a disconnected device
+func (r *reconnector) recordDisconnected(id peer.ID) {
+	dv := r.cc.DeviceManager.GetDevice(id.String())
+	if dv == nil {
+		return
+	}
+	dv.UpdateAddressBook(r.cc.Host.Peerstore().Addrs(id))
+	r.cc.DeviceManager.UpdateDevice(dv)
+}
+
+// redialDevices send the trusted disconnected devices which are ready to redial to the discovery loop
+func (r *reconnector) redialDevices(ctx context.Context) {
+	now := time.Now()
+
+	for _, dv := range r.cc.DeviceManager.ListDevices() {
+		id := dv.AddressInfo.ID
+		if id == "" || dv.PgpEncrypter == nil {
+			// not trusted device
+			continue
+		}
+
+		if dv.Status == device.StatusConnected {
+			r.reset(id)
+			continue
+		}
+		if dv.Status != device.StatusDisconnected && dv.Status != device.StatusError {
+			continue
+		}
+		if r.cc.Host.Network().Connectedness(id) == network.Connected {
+			continue
+		}
+
+		addrs := r.cc.Host.Peerstore().Addrs(id)
+		if len(addrs) == 0 {
+			addrs = dv.AddressInfo.Addrs
+		}
+		if len(addrs) == 0 {
+			continue
+		}
+
+		if !r.attempt(id, now) {
+			continue
+		}
+
+		r.cc.LogChan <- fmt.Sprintf("redialing device %s", dv.Name)
+		select {
+		case r.cc.NewPeerChan <- peer.AddrInfo{ID: id, Addrs: addrs}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// attempt returns true and schedule the next attempt if the device is ready to redial
+func (r *reconnector) attempt(id peer.ID, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.backoffs[id]
+	if !ok {
+		b = newBackoff(reconnectMinBackoff, reconnectMaxBackoff)
+		r.backoffs[id] = b
+	}
+	if !b.Ready(now) {
+		return false
+	}
+	b.Attempt(now)
+	return true
+}
+
+// reset reset the backoff of a connected device
+func (r *reconnector) reset(id peer.ID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.backoffs, id)
+}
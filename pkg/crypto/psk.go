@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/libp2p/go-libp2p/core/pnet"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const pskSize int = 32
+
+// GeneratePSK generate a random pre-shared key for the group private network in hex
+func GeneratePSK() (string, error) {
+	psk := make([]byte, pskSize)
+	_, err := rand.Read(psk)
+	if err != nil {
+		return "", xerror.NewRuntimeError("unable to generate psk").Wrap(err)
+	}
+	return hex.EncodeToString(psk), nil
+}
+
+// DecodePSK decode the hex pre-shared key
+func DecodePSK(hexPSK string) (pnet.PSK, error) {
+	psk, err := hex.DecodeString(hexPSK)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("psk is not hex encoded").Wrap(err)
+	}
+	if len(psk) != pskSize {
+		return nil, xerror.NewRuntimeErrorf("psk size %d is not %d bytes", len(psk), pskSize)
+	}
+	return pnet.PSK(psk), nil
+}
@@ -0,0 +1,59 @@
+package crypto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPSK(t *testing.T) {
+	hexPSK, err := GeneratePSK()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	psk, err := DecodePSK(hexPSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(psk) != pskSize {
+		t.Fatalf("got psk size %d, want %d", len(psk), pskSize)
+	}
+
+	again, err := DecodePSK(hexPSK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(psk, again) {
+		t.Errorf("got %x, wanted %x", again, psk)
+	}
+}
+
+func TestDecodePSKInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		psk  string
+	}{
+		{
+			name: "not hex",
+			psk:  strings.Repeat("zz", pskSize),
+		},
+		{
+			name: "too short",
+			psk:  strings.Repeat("ab", pskSize-1),
+		},
+		{
+			name: "empty",
+			psk:  "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := DecodePSK(test.psk)
+			if err == nil {
+				t.Fatalf("DecodePSK(%q) should return error", test.psk)
+			}
+		})
+	}
+}
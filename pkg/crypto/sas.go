@@ -0,0 +1,68 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+const (
+	sasInfo      string = "cross-clipboard pairing sas"
+	sasEmojiSize int    = 5
+)
+
+// sasEmojis 64 emojis, each emoji of the short authentication string is picked by 6 bits
+var sasEmojis = [64]string{
+	"🐶", "🐱", "🦁", "🐴", "🦄", "🐷", "🐘", "🐰",
+	"🐼", "🐓", "🐧", "🐢", "🐟", "🐙", "🦋", "🌷",
+	"🌳", "🌵", "🍄", "🌏", "🌙", "☁️", "🔥", "🍌",
+	"🍎", "🍓", "🌽", "🍕", "🎂", "❤️", "😀", "🤖",
+	"🎩", "👓", "🔧", "🎅", "👍", "☂️", "⌛", "⏰",
+	"🎁", "💡", "📕", "✏️", "📎", "✂️", "🔒", "🔑",
+	"🔨", "☎️", "🏁", "🚂", "🚲", "✈️", "🚀", "🏆",
+	"⚽", "🎸", "🎺", "🔔", "⚓", "🎧", "📁", "📌",
+}
+
+// ShortAuthString the short authentication string of a pairing, both devices get the same one
+// so the users can compare it to make sure they are trusting the right device
+type ShortAuthString struct {
+	Digits string
+	Emojis []string
+}
+
+// NewShortAuthString derive the short authentication string from the peer ids and pgp key fingerprints of both devices,
+// the order of the devices doesn't change the result
+func NewShortAuthString(idA, fingerprintA, idB, fingerprintB string) ShortAuthString {
+	// sort the devices so both sides hash the same input
+	if idA > idB {
+		idA, fingerprintA, idB, fingerprintB = idB, fingerprintB, idA, fingerprintA
+	}
+
+	h := sha256.New()
+	for _, field := range []string{sasInfo, idA, strings.ToLower(fingerprintA), idB, strings.ToLower(fingerprintB)} {
+		// length prefix to avoid ambiguous concatenation
+		binary.Write(h, binary.BigEndian, uint32(len(field)))
+		h.Write([]byte(field))
+	}
+	sum := h.Sum(nil)
+
+	digits := binary.BigEndian.Uint32(sum[0:4]) % 1000000
+
+	// take 6 bits for each emoji from the next bytes
+	bits := binary.BigEndian.Uint64(sum[4:12])
+	emojis := make([]string, sasEmojiSize)
+	for i := range emojis {
+		emojis[i] = sasEmojis[(bits>>(58-6*i))&0x3F]
+	}
+
+	return ShortAuthString{
+		Digits: fmt.Sprintf("%03d %03d", digits/1000, digits%1000),
+		Emojis: emojis,
+	}
+}
+
+// String returns the digits and the emojis
+func (s ShortAuthString) String() string {
+	return fmt.Sprintf("%s  %s", s.Digits, strings.Join(s.Emojis, " "))
+}
@@ -0,0 +1,78 @@
+package crypto
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestShortAuthString(t *testing.T) {
+	idA, fingerprintA := "12D3KooWA", "aabbccdd"
+	idB, fingerprintB := "12D3KooWB", "11223344"
+
+	got := NewShortAuthString(idA, fingerprintA, idB, fingerprintB)
+	if len(got.Digits) != 7 {
+		t.Fatalf("got digits %q, want 7 characters", got.Digits)
+	}
+	if len(got.Emojis) != sasEmojiSize {
+		t.Fatalf("got %d emojis, want %d", len(got.Emojis), sasEmojiSize)
+	}
+
+	tests := []struct {
+		name         string
+		idA          string
+		fingerprintA string
+		idB          string
+		fingerprintB string
+		wantSame     bool
+	}{
+		{
+			name:         "same devices",
+			idA:          idA,
+			fingerprintA: fingerprintA,
+			idB:          idB,
+			fingerprintB: fingerprintB,
+			wantSame:     true,
+		},
+		{
+			name:         "swapped devices",
+			idA:          idB,
+			fingerprintA: fingerprintB,
+			idB:          idA,
+			fingerprintB: fingerprintA,
+			wantSame:     true,
+		},
+		{
+			name:         "fingerprint case",
+			idA:          idA,
+			fingerprintA: "AABBCCDD",
+			idB:          idB,
+			fingerprintB: fingerprintB,
+			wantSame:     true,
+		},
+		{
+			name:         "different key",
+			idA:          idA,
+			fingerprintA: "aabbccde",
+			idB:          idB,
+			fingerprintB: fingerprintB,
+			wantSame:     false,
+		},
+		{
+			name:         "different peer",
+			idA:          "12D3KooWC",
+			fingerprintA: fingerprintA,
+			idB:          idB,
+			fingerprintB: fingerprintB,
+			wantSame:     false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sas := NewShortAuthString(test.idA, test.fingerprintA, test.idB, test.fingerprintB)
+			if same := reflect.DeepEqual(sas, got); same != test.wantSame {
+				t.Errorf("got %s, compare with %s same = %v, want %v", sas, got, same, test.wantSame)
+			}
+		})
+	}
+}
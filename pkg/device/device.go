@@ -1,10 +1,14 @@
 package device
 
 import (
-	"bufio"
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
 	"github.com/yqs112358/cross-clipboard/pkg/crypto"
 	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
@@ -19,11 +23,33 @@ type Device struct {
 	PublicKey []byte       `json:"publicKey"`
 	Status    DeviceStatus `json:"status"`
 
-	Stream network.Stream `json:"-"`
-	Writer *bufio.Writer  `json:"-"`
-	Reader *bufio.Reader  `json:"-"`
+	// PendingPublicKey a different public key presented by the trusted device, waiting for the user to re-approve it
+	PendingPublicKey []byte `json:"-"`
+
+	// address book to reconnect the device after restart
+	Addrs    []string  `json:"addrs"`
+	LastSeen time.Time `json:"lastSeen"`
+
+	// ControlStream the stream of the handshakes, signals and receipts
+	ControlStream *Stream `json:"-"`
+	// dataStream the stream of the clipboard payloads on the same connection,
+	// so a large transfer doesn't delay the signals
+	dataStream   *Stream
+	dataStreamMu sync.RWMutex
 
 	PgpEncrypter *crypto.PGPEncrypter `json:"-"`
+
+	// Trusted the user approved the device, only the approved device is trusted after restart
+	Trusted bool `json:"trusted"`
+
+	// PeerConfirmed the device confirmed the pairing with this device, it's kept so the device is connected after restart
+	PeerConfirmed bool `json:"peerConfirmed"`
+
+	// Capabilities the features supported by both devices, agreed in the hello
+	Capabilities []string `json:"-"`
+
+	// rtt the round trip time measured by the last heartbeat, it's set by the heartbeat of the device
+	rtt atomic.Int64
 }
 
 // NewDevice initial new peer
@@ -32,34 +58,100 @@ func NewDevice(
 	stream network.Stream,
 ) *Device {
 	return &Device{
-		AddressInfo: addrInfo,
-		Stream:      stream,
-		Reader:      bufio.NewReader(stream),
-		Writer:      bufio.NewWriter(stream),
+		AddressInfo:   addrInfo,
+		ControlStream: NewStream(stream),
 	}
 }
 
-// Trust trust this device and change status to connected
+// Trust trust this device, the status is changed to connected when the device also confirmed the pairing,
+// a pending public key replaces the pinned one
 func (dv *Device) Trust() error {
+	if dv.PendingPublicKey != nil {
+		dv.PublicKey = dv.PendingPublicKey
+		dv.PendingPublicKey = nil
+	}
+
 	err := dv.CreatePGPEncrypter()
 	if err != nil {
 		return xerror.NewRuntimeError("can not create pgp encrypter").Wrap(err)
 	}
+	dv.Trusted = true
 
-	dv.Status = StatusConnected
+	if dv.PeerConfirmed {
+		dv.Status = StatusConnected
+	} else {
+		dv.Status = StatusPending
+	}
 	return nil
 }
 
+// RTT returns the round trip time measured by the last heartbeat, 0 if it's not measured yet
+func (dv *Device) RTT() time.Duration {
+	return time.Duration(dv.rtt.Load())
+}
+
+// SetRTT record the round trip time measured by the heartbeat
+func (dv *Device) SetRTT(rtt time.Duration) {
+	dv.rtt.Store(int64(rtt))
+}
+
+// IsTrusted returns true if this device is trusted by the user
+func (dv *Device) IsTrusted() bool {
+	return dv.PgpEncrypter != nil
+}
+
+// ConfirmPairing record the device confirmed the pairing, the status is changed to connected if it's trusted
+func (dv *Device) ConfirmPairing() {
+	dv.PeerConfirmed = true
+	if dv.IsTrusted() && dv.Status == StatusPending {
+		dv.Status = StatusConnected
+	}
+}
+
+// Fingerprint returns the fingerprint of the device pgp public key waiting to be trusted,
+// it's the pending public key when the key is changed
+func (dv *Device) Fingerprint() (string, error) {
+	key := dv.PublicKey
+	if dv.PendingPublicKey != nil {
+		key = dv.PendingPublicKey
+	}
+	publicKey, err := crypto.ByteToPGPKey(key)
+	if err != nil {
+		return "", xerror.NewRuntimeError("error to create pgp public key").Wrap(err)
+	}
+	return publicKey.GetFingerprint(), nil
+}
+
+// HasCapability returns true if the feature is supported by both devices
+func (dv *Device) HasCapability(capability string) bool {
+	for _, c := range dv.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // Block block this device
 func (dv *Device) Block() {
 	dv.Status = StatusBlocked
 }
 
-// UpdateFromProtobuf update device from protobuf device data
-func (dv *Device) UpdateFromProtobuf(deviceData *protobuf.DeviceData) {
+// UpdateFromProtobuf update device from protobuf device data,
+// the public key of a trusted device is pinned, a different key is kept as pending and the status is changed to key changed
+func (dv *Device) UpdateFromProtobuf(deviceData *protobuf.DeviceData) (keyChanged bool) {
 	dv.Name = deviceData.Name
 	dv.OS = deviceData.Os
+
+	if dv.IsTrusted() && !bytes.Equal(dv.PublicKey, deviceData.PublicKey) {
+		dv.PendingPublicKey = deviceData.PublicKey
+		dv.Status = StatusKeyChanged
+		return true
+	}
+
 	dv.PublicKey = deviceData.PublicKey
+	dv.PendingPublicKey = nil
+	return false
 }
 
 func (dv *Device) CreatePGPEncrypter() error {
@@ -75,3 +167,28 @@ func (dv *Device) CreatePGPEncrypter() error {
 
 	return nil
 }
+
+// UpdateAddressBook record the last known addresses and update the last seen time
+func (dv *Device) UpdateAddressBook(addrs []multiaddr.Multiaddr) {
+	if len(addrs) > 0 {
+		dv.AddressInfo.Addrs = addrs
+		dv.Addrs = make([]string, 0, len(addrs))
+		for _, addr := range addrs {
+			dv.Addrs = append(dv.Addrs, addr.String())
+		}
+	}
+	dv.LastSeen = time.Now()
+}
+
+// AddressBookAddrs returns the valid multiaddrs in the address book
+func (dv *Device) AddressBookAddrs() []multiaddr.Multiaddr {
+	addrs := make([]multiaddr.Multiaddr, 0, len(dv.Addrs))
+	for _, addr := range dv.Addrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			continue
+		}
+		addrs = append(addrs, maddr)
+	}
+	return addrs
+}
@@ -0,0 +1,185 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+)
+
+func TestUpdateFromProtobufPinsPublicKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		trusted        bool
+		newKey         []byte
+		wantKeyChanged bool
+		wantPublicKey  []byte
+		wantPendingKey []byte
+		wantStatus     DeviceStatus
+	}{
+		{
+			name:          "untrusted device takes the new key",
+			newKey:        []byte("new"),
+			wantPublicKey: []byte("new"),
+			wantStatus:    StatusPending,
+		},
+		{
+			name:          "trusted device with the same key",
+			trusted:       true,
+			newKey:        []byte("old"),
+			wantPublicKey: []byte("old"),
+			wantStatus:    StatusPending,
+		},
+		{
+			name:           "trusted device with a different key",
+			trusted:        true,
+			newKey:         []byte("new"),
+			wantKeyChanged: true,
+			wantPublicKey:  []byte("old"),
+			wantPendingKey: []byte("new"),
+			wantStatus:     StatusKeyChanged,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dv := &Device{PublicKey: []byte("old"), Status: StatusPending}
+			if test.trusted {
+				dv.PgpEncrypter = &crypto.PGPEncrypter{}
+			}
+
+			keyChanged := dv.UpdateFromProtobuf(&protobuf.DeviceData{Name: "device", PublicKey: test.newKey})
+			if keyChanged != test.wantKeyChanged {
+				t.Errorf("got key changed %v, wanted %v", keyChanged, test.wantKeyChanged)
+			}
+			if !bytes.Equal(dv.PublicKey, test.wantPublicKey) {
+				t.Errorf("got public key %s, wanted %s", dv.PublicKey, test.wantPublicKey)
+			}
+			if !bytes.Equal(dv.PendingPublicKey, test.wantPendingKey) {
+				t.Errorf("got pending public key %s, wanted %s", dv.PendingPublicKey, test.wantPendingKey)
+			}
+			if dv.Status != test.wantStatus {
+				t.Errorf("got status %s, wanted %s", dv.Status, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestClearDataStream(t *testing.T) {
+	current := &Stream{}
+	replaced := &Stream{}
+	tests := []struct {
+		name       string
+		clear      *Stream
+		wantClear  bool
+		wantStream *Stream
+	}{
+		{name: "current stream", clear: current, wantClear: true, wantStream: nil},
+		{name: "replaced stream", clear: replaced, wantClear: false, wantStream: current},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dv := &Device{}
+			dv.SetDataStream(current)
+
+			if got := dv.ClearDataStream(test.clear); got != test.wantClear {
+				t.Errorf("got cleared %v, wanted %v", got, test.wantClear)
+			}
+			if got := dv.DataStream(); got != test.wantStream {
+				t.Errorf("got data stream %p, wanted %p", got, test.wantStream)
+			}
+		})
+	}
+}
+
+func TestReplaceControlStream(t *testing.T) {
+	const testProtocol = "/cross-clipboard/test/1.0.0"
+
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mn.Close()
+	thisHost, peerHost := mn.Hosts()[0], mn.Hosts()[1]
+	lower, higher := thisHost, peerHost
+	if peerHost.ID() < thisHost.ID() {
+		lower, higher = peerHost, thisHost
+	}
+
+	accepted := make(chan network.Stream, 1)
+	thisHost.SetStreamHandler(testProtocol, func(stream network.Stream) {
+		accepted <- stream
+	})
+	peerHost.SetStreamHandler(testProtocol, func(stream network.Stream) {})
+
+	// openStream returns the stream of this host opened by the dialer
+	openStream := func(t *testing.T, dialer host.Host) network.Stream {
+		if dialer == thisHost {
+			stream, err := thisHost.NewStream(context.Background(), peerHost.ID(), testProtocol)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return stream
+		}
+		stream, err := peerHost.NewStream(context.Background(), thisHost.ID(), testProtocol)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the protocol is negotiated with the first write
+		go stream.Write([]byte{0})
+		select {
+		case stream := <-accepted:
+			return stream
+		case <-time.After(5 * time.Second):
+			t.Fatal("the stream is not accepted")
+			return nil
+		}
+	}
+
+	tests := []struct {
+		name         string
+		oldDialer    host.Host
+		newDialer    host.Host
+		closeOld     bool
+		wantReplaced bool
+	}{
+		{name: "simultaneous dial keeps the stream of the lower peer", oldDialer: lower, newDialer: higher, wantReplaced: false},
+		{name: "simultaneous dial replaces the stream of the higher peer", oldDialer: higher, newDialer: lower, wantReplaced: true},
+		{name: "same peer dials again", oldDialer: higher, newDialer: higher, wantReplaced: true},
+		{name: "closed stream", oldDialer: lower, newDialer: higher, closeOld: true, wantReplaced: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dv := NewDevice(peerHost.Peerstore().PeerInfo(peerHost.ID()), openStream(t, test.oldDialer))
+			old := dv.ControlStream
+			if test.closeOld {
+				old.Close()
+			}
+			stream := openStream(t, test.newDialer)
+			defer stream.Reset()
+
+			if got := dv.ReplaceControlStream(stream); got != test.wantReplaced {
+				t.Fatalf("got replaced %v, wanted %v", got, test.wantReplaced)
+			}
+			if test.wantReplaced {
+				if dv.ControlStream.Stream != stream {
+					t.Error("the control stream is not replaced")
+				}
+				if old.Alive() {
+					t.Error("the old stream is still alive")
+				}
+			} else if dv.ControlStream != old || !old.Alive() {
+				t.Error("the live stream of the lower peer is not kept")
+			}
+			old.Reset()
+		})
+	}
+}
@@ -4,7 +4,8 @@ package device
 type DeviceStatus string
 
 const (
-	// StatusPending the device waiting to handshake and trust the device, they send you the public key but you didn't
+	// StatusPending the device waiting to handshake and trust the device, they send you the public key but you didn't,
+	// or you trusted the device but it didn't confirm the pairing yet
 	StatusPending DeviceStatus = "pending"
 	// StatusConnected the device is trusted and connected
 	StatusConnected DeviceStatus = "connected"
@@ -12,6 +13,8 @@ const (
 	StatusDisconnected DeviceStatus = "disconnected"
 	// StatusError found a error in the device should disconnect and reconnect
 	StatusError DeviceStatus = "error"
+	// StatusKeyChanged the trusted device presented a different public key, traffic is refused until the user re-approves it
+	StatusKeyChanged DeviceStatus = "key_changed"
 	// StatusBlocked the device is blocked by the user
 	StatusBlocked DeviceStatus = "blocked"
 )
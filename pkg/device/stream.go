@@ -0,0 +1,130 @@
+package device
+
+import (
+	"bufio"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Stream a libp2p stream of the device with its buffered reader and writer
+type Stream struct {
+	network.Stream
+	Reader *bufio.Reader
+	Writer *bufio.Writer
+	// WriteMu serialize the writes to the stream from multiple goroutines
+	WriteMu sync.Mutex
+
+	closed atomic.Bool
+}
+
+// NewStream wrap the libp2p stream with a buffered reader and writer
+func NewStream(stream network.Stream) *Stream {
+	return &Stream{
+		Stream: stream,
+		Reader: bufio.NewReader(stream),
+		Writer: bufio.NewWriter(stream),
+	}
+}
+
+// Close close the stream, it's no longer alive
+func (s *Stream) Close() error {
+	s.closed.Store(true)
+	return s.Stream.Close()
+}
+
+// Reset reset the stream, it's no longer alive
+func (s *Stream) Reset() error {
+	s.closed.Store(true)
+	return s.Stream.Reset()
+}
+
+// Alive returns true if the stream is not closed by this host and its connection is open
+func (s *Stream) Alive() bool {
+	return !s.closed.Load() && !s.Conn().IsClosed()
+}
+
+// Dialer returns the peer that opened the stream
+func Dialer(stream network.Stream) peer.ID {
+	if stream.Stat().Direction == network.DirOutbound {
+		return stream.Conn().LocalPeer()
+	}
+	return stream.Conn().RemotePeer()
+}
+
+// DataStream returns the stream of the clipboard payloads, or nil if it's not opened
+func (dv *Device) DataStream() *Stream {
+	dv.dataStreamMu.RLock()
+	defer dv.dataStreamMu.RUnlock()
+	return dv.dataStream
+}
+
+// SetDataStream set the stream of the clipboard payloads
+func (dv *Device) SetDataStream(ds *Stream) {
+	dv.dataStreamMu.Lock()
+	dv.dataStream = ds
+	dv.dataStreamMu.Unlock()
+}
+
+// ClearDataStream remove the data stream if it's still the giving stream, returns true if it's removed
+func (dv *Device) ClearDataStream(ds *Stream) bool {
+	dv.dataStreamMu.Lock()
+	defer dv.dataStreamMu.Unlock()
+	if dv.dataStream != ds {
+		return false
+	}
+	dv.dataStream = nil
+	return true
+}
+
+// CloseStreams close the control stream and the data stream of the device
+func (dv *Device) CloseStreams() error {
+	var errs []error
+	if ds := dv.DataStream(); ds != nil {
+		dv.ClearDataStream(ds)
+		errs = append(errs, ds.Close())
+	}
+	if dv.ControlStream != nil {
+		errs = append(errs, dv.ControlStream.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// HasLiveControlStream returns true if the control stream of the device is alive
+func (dv *Device) HasLiveControlStream() bool {
+	return dv.ControlStream != nil && dv.ControlStream.Alive()
+}
+
+// KeepsControlStream returns true if the live control stream is kept against a new one opened by the dialer,
+// the stream opened by the lower peer id wins
+func (dv *Device) KeepsControlStream(dialer peer.ID) bool {
+	if !dv.HasLiveControlStream() {
+		return false
+	}
+	oldDialer := Dialer(dv.ControlStream)
+	return oldDialer != dialer && oldDialer < dialer
+}
+
+// ReplaceControlStream set the control stream of a new connection of the device and close the streams of the old one,
+// the new stream is set first so the readers of the old streams know they are replaced.
+// When both devices dial each other at the same time, the stream opened by the lower peer id is kept on both sides,
+// returns false if the live old stream is kept and the new one must be closed
+func (dv *Device) ReplaceControlStream(stream network.Stream) bool {
+	if dv.KeepsControlStream(Dialer(stream)) {
+		return false
+	}
+
+	old := dv.ControlStream
+	dv.ControlStream = NewStream(stream)
+	if ds := dv.DataStream(); ds != nil {
+		dv.ClearDataStream(ds)
+		ds.Close()
+	}
+	if old != nil {
+		old.Close()
+	}
+	return true
+}
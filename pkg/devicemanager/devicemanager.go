@@ -1,44 +1,76 @@
 package devicemanager
 
 import (
+	"sync"
+
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 )
 
 type DeviceManager struct {
 	Devices        map[string]*device.Device
-	DevicesUpdated chan struct{}
+	DevicesUpdated chan struct{} // notified when devices are updated, multiple updates may be merged into one notification
 
 	config *config.Config
+	mu     sync.RWMutex
 }
 
 func NewDeviceManager(cfg *config.Config) *DeviceManager {
 	return &DeviceManager{
 		Devices:        make(map[string]*device.Device),
-		DevicesUpdated: make(chan struct{}),
+		DevicesUpdated: make(chan struct{}, 1),
 		config:         cfg,
 	}
 }
 
 func (dm *DeviceManager) AddDevice(device *device.Device) {
+	dm.mu.Lock()
 	dm.Devices[device.AddressInfo.ID.String()] = device
-	dm.DevicesUpdated <- struct{}{}
+	dm.mu.Unlock()
+	dm.notifyUpdated()
 }
 
 func (dm *DeviceManager) RemoveDevice(device *device.Device) {
 	// Flush and close ignore error
-	device.Writer.Flush()
-	device.Stream.Close()
+	if device.ControlStream != nil {
+		device.ControlStream.Writer.Flush()
+	}
+	device.CloseStreams()
+	dm.mu.Lock()
 	delete(dm.Devices, device.AddressInfo.ID.String())
-	dm.DevicesUpdated <- struct{}{}
+	dm.mu.Unlock()
+	dm.notifyUpdated()
 }
 
 func (dm *DeviceManager) GetDevice(id string) *device.Device {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
 	return dm.Devices[id]
 }
 
+// ListDevices returns a snapshot of all devices, safe to use while devices are updated
+func (dm *DeviceManager) ListDevices() []*device.Device {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+	devices := make([]*device.Device, 0, len(dm.Devices))
+	for _, dv := range dm.Devices {
+		devices = append(devices, dv)
+	}
+	return devices
+}
+
 func (dm *DeviceManager) UpdateDevice(device *device.Device) {
+	dm.mu.Lock()
 	dm.Devices[device.AddressInfo.ID.String()] = device
-	dm.DevicesUpdated <- struct{}{}
+	dm.mu.Unlock()
+	dm.notifyUpdated()
 	dm.Save()
 }
+
+// notifyUpdated notify devices updated without blocking, the caller may be the one receiving the notification
+func (dm *DeviceManager) notifyUpdated() {
+	select {
+	case dm.DevicesUpdated <- struct{}{}:
+	default:
+	}
+}
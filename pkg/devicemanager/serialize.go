@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 
+	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 	"github.com/yqs112358/cross-clipboard/pkg/utils/stringutil"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
@@ -13,8 +14,11 @@ import (
 
 const devicesFileName = "devices.json"
 
+// Save write the devices and their address book to the devices file
 func (dm *DeviceManager) Save() error {
+	dm.mu.RLock()
 	b, err := json.MarshalIndent(dm.Devices, "", "  ")
+	dm.mu.RUnlock()
 	if err != nil {
 		return xerror.NewRuntimeError("can not marshal devices").Wrap(err)
 	}
@@ -29,6 +33,7 @@ func (dm *DeviceManager) Save() error {
 	return nil
 }
 
+// Load read the devices from the devices file, the address info is restored from the address book
 func (dm *DeviceManager) Load() error {
 	deviceFilePath := stringutil.JoinURL(dm.config.ConfigDirPath, devicesFileName)
 
@@ -39,6 +44,7 @@ func (dm *DeviceManager) Load() error {
 		}
 		return xerror.NewRuntimeError("can not open devices file").Wrap(err)
 	}
+	defer f.Close()
 	bytes, err := io.ReadAll(f)
 	if err != nil {
 		return xerror.NewRuntimeError("can not read devices file").Wrap(err)
@@ -50,18 +56,34 @@ func (dm *DeviceManager) Load() error {
 		return xerror.NewRuntimeError("can not unmarshal devices json").Wrap(err)
 	}
 
-	for _, dv := range devices {
-		if dv.Status != device.StatusBlocked {
+	for id, dv := range devices {
+		peerID, err := peer.Decode(id)
+		if err != nil {
+			return xerror.NewRuntimeErrorf("invalid device id %s", id).Wrap(err)
+		}
+		dv.AddressInfo = peer.AddrInfo{
+			ID:    peerID,
+			Addrs: dv.AddressBookAddrs(),
+		}
+
+		switch {
+		case dv.Status == device.StatusBlocked:
+		case dv.Trusted:
 			dv.Status = device.StatusDisconnected
 			err := dv.CreatePGPEncrypter()
 			if err != nil {
 				return xerror.NewRuntimeError("can not create pgp encrypter").Wrap(err)
 			}
+		default:
+			// the device not approved by the user is still waiting for the approval
+			dv.Status = device.StatusPending
 		}
 	}
 
+	dm.mu.Lock()
 	dm.Devices = devices
-	dm.DevicesUpdated <- struct{}{}
+	dm.mu.Unlock()
+	dm.notifyUpdated()
 
 	return nil
 }
@@ -0,0 +1,97 @@
+package devicemanager
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+)
+
+func TestLoad(t *testing.T) {
+	armored, err := crypto.GeneratePGPKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.UnmarshalPGPKey(armored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := key.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		status      device.DeviceStatus
+		trusted     bool
+		wantStatus  device.DeviceStatus
+		wantTrusted bool
+	}{
+		{
+			name:        "trusted device",
+			status:      device.StatusConnected,
+			trusted:     true,
+			wantStatus:  device.StatusDisconnected,
+			wantTrusted: true,
+		},
+		{
+			name:       "pending device confirmed by the peer",
+			status:     device.StatusPending,
+			wantStatus: device.StatusPending,
+		},
+		{
+			name:       "blocked device",
+			status:     device.StatusBlocked,
+			trusted:    true,
+			wantStatus: device.StatusBlocked,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{ConfigDirPath: t.TempDir()}
+			id, _, err := crypto.NewKeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			peerID, err := peer.IDFromPrivateKey(id)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			saved := NewDeviceManager(cfg)
+			dv := &device.Device{
+				Name:          "saved",
+				PublicKey:     publicKey,
+				Status:        test.status,
+				Trusted:       test.trusted,
+				PeerConfirmed: true,
+			}
+			dv.AddressInfo.ID = peerID
+			saved.AddDevice(dv)
+			err = saved.Save()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loaded := NewDeviceManager(cfg)
+			err = loaded.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := loaded.GetDevice(peerID.String())
+			if got == nil {
+				t.Fatal("the saved device is not loaded")
+			}
+			if got.Status != test.wantStatus {
+				t.Errorf("got status %s, wanted %s", got.Status, test.wantStatus)
+			}
+			if got.IsTrusted() != test.wantTrusted {
+				t.Errorf("got trusted %v, wanted %v", got.IsTrusted(), test.wantTrusted)
+			}
+		})
+	}
+}
@@ -1,10 +1,18 @@
 package discovery
 
 import (
+	"context"
+
 	"github.com/libp2p/go-libp2p/core/host"
 	"github.com/libp2p/go-libp2p/core/peer"
 )
 
+// Discoverer discover peers for the service, each discoverer is registered by name
+// and can be enabled by `discovery.<name>.enabled` in config
 type Discoverer interface {
-	Init(host host.Host, serviceName string, logChan chan string) (chan peer.AddrInfo, error)
+	// Start start discovering peers until the context is done or Stop is called,
+	// the discovered peers are sent to the returned channel
+	Start(ctx context.Context, host host.Host, serviceName string, logChan chan string) (<-chan peer.AddrInfo, error)
+	// Stop stop discovering peers and release the resources
+	Stop() error
 }
@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// Manager start the enabled discoverers and merge the discovered peers into one channel
+type Manager struct {
+	cfg *config.Config
+
+	discoverers map[string]Discoverer
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+// NewManager create a new discovery manager
+func NewManager(cfg *config.Config) *Manager {
+	return &Manager{
+		cfg:         cfg,
+		discoverers: make(map[string]Discoverer),
+	}
+}
+
+// Start start all enabled discoverers, the discovered peers are sent to peerChan.
+// a discoverer failed to start does not stop the others, the errors are joined and returned.
+func (m *Manager) Start(ctx context.Context, peerHost host.Host, peerChan chan<- peer.AddrInfo, logChan chan string) error {
+	ctx, m.cancel = context.WithCancel(ctx)
+
+	var errs []error
+	for _, name := range Names() {
+		if !m.cfg.Discovery.IsEnabled(name) {
+			continue
+		}
+
+		discoverer := registry[name](m.cfg)
+		foundChan, err := discoverer.Start(ctx, peerHost, m.cfg.GroupName, logChan)
+		if err != nil {
+			errs = append(errs, xerror.NewRuntimeErrorf("error to start %s discoverer", name).Wrap(err))
+			continue
+		}
+		m.discoverers[name] = discoverer
+		logChan <- fmt.Sprintf("started %s discoverer", name)
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			forwardPeers(ctx, foundChan, peerChan)
+		}()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Stop stop all started discoverers
+func (m *Manager) Stop() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	var errs []error
+	for name, discoverer := range m.discoverers {
+		err := discoverer.Stop()
+		if err != nil {
+			errs = append(errs, xerror.NewRuntimeErrorf("error to stop %s discoverer", name).Wrap(err))
+		}
+		delete(m.discoverers, name)
+	}
+	m.wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// forwardPeers forward peers from a discoverer channel to the merged channel until the context is done
+func forwardPeers(ctx context.Context, in <-chan peer.AddrInfo, out chan<- peer.AddrInfo) {
+	for {
+		select {
+		case peerInfo, ok := <-in:
+			if !ok {
+				return
+			}
+			select {
+			case out <- peerInfo:
+			case <-ctx.Done():
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package discovery
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func TestForwardPeers(t *testing.T) {
+	tests := []struct {
+		name  string
+		peers []peer.AddrInfo
+	}{
+		{
+			name:  "forward all peers",
+			peers: []peer.AddrInfo{{ID: peer.ID("a")}, {ID: peer.ID("b")}},
+		},
+		{
+			name:  "no peer",
+			peers: []peer.AddrInfo{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := make(chan peer.AddrInfo, len(test.peers))
+			out := make(chan peer.AddrInfo, len(test.peers))
+			for _, p := range test.peers {
+				in <- p
+			}
+			close(in)
+
+			forwardPeers(context.Background(), in, out)
+			close(out)
+
+			got := []peer.AddrInfo{}
+			for p := range out {
+				got = append(got, p)
+			}
+			if !reflect.DeepEqual(got, test.peers) {
+				t.Fatalf("got %v, want %v", got, test.peers)
+			}
+		})
+	}
+}
+
+func TestForwardPeersStopWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan peer.AddrInfo, 1)
+	in <- peer.AddrInfo{ID: peer.ID("a")}
+	cancel()
+
+	// the output channel is never read, forwardPeers must return after the context is done
+	forwardPeers(ctx, in, make(chan peer.AddrInfo))
+}
@@ -1,7 +1,9 @@
 package discovery
 
 import (
+	"context"
 	"fmt"
+
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 
 	"github.com/libp2p/go-libp2p/core/host"
@@ -10,42 +12,63 @@ import (
 	"github.com/libp2p/go-libp2p/p2p/discovery/mdns"
 )
 
+func init() {
+	Register("mdns", func(c *config.Config) Discoverer {
+		return NewMdnsDiscoverer(c)
+	})
+}
+
 // DiscoveryNotifee noti struct when discover a new peer
 type DiscoveryNotifee struct {
 	PeerHost host.Host
 	PeerChan chan peer.AddrInfo
 	LogChan  chan string
+
+	ctx context.Context
 }
 
 // HandlePeerFound interface to be called when new  peer is found
 func (n *DiscoveryNotifee) HandlePeerFound(peerInfo peer.AddrInfo) {
 	n.LogChan <- fmt.Sprintf("discovered peer: %s", peerInfo)
 	if n.PeerHost.ID() != peerInfo.ID {
-		n.PeerChan <- peerInfo
+		select {
+		case n.PeerChan <- peerInfo:
+		case <-n.ctx.Done():
+		}
 	}
 }
 
 type MulticastDNS struct {
 	cfg *config.Config
+
+	service mdns.Service
 }
 
 func NewMdnsDiscoverer(c *config.Config) *MulticastDNS {
 	return &MulticastDNS{cfg: c}
 }
 
-func (m *MulticastDNS) Init(peerHost host.Host, serviceName string, peerChan chan peer.AddrInfo, logChan chan string) error {
+func (m *MulticastDNS) Start(ctx context.Context, peerHost host.Host, serviceName string, logChan chan string) (<-chan peer.AddrInfo, error) {
 	// register with service so that we get notified about peer discovery
 	n := &DiscoveryNotifee{
 		PeerHost: peerHost,
-		PeerChan: peerChan,
+		PeerChan: make(chan peer.AddrInfo),
 		LogChan:  logChan,
+		ctx:      ctx,
 	}
 
 	// An hour might be a long long period in practical applications. But this is fine for us
-	ser := mdns.NewMdnsService(peerHost, serviceName, n)
-	if err := ser.Start(); err != nil {
-		return err
+	m.service = mdns.NewMdnsService(peerHost, serviceName, n)
+	if err := m.service.Start(); err != nil {
+		return nil, err
 	}
 
-	return nil
+	return n.PeerChan, nil
+}
+
+func (m *MulticastDNS) Stop() error {
+	if m.service == nil {
+		return nil
+	}
+	return m.service.Close()
 }
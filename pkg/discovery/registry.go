@@ -0,0 +1,31 @@
+package discovery
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+)
+
+// NewDiscovererFunc create a new discoverer from config
+type NewDiscovererFunc func(cfg *config.Config) Discoverer
+
+var registry = make(map[string]NewDiscovererFunc)
+
+// Register register a discoverer by name, it should be called in init of the discoverer file
+func Register(name string, newFunc NewDiscovererFunc) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("discoverer %s already registered", name))
+	}
+	registry[name] = newFunc
+}
+
+// Names returns the names of all registered discoverers in sorted order
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
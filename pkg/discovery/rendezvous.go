@@ -0,0 +1,139 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/discovery/routing"
+	"github.com/libp2p/go-libp2p/p2p/discovery/util"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+	"github.com/yqs112358/cross-clipboard/pkg/rendezvous"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// bootstrapConnectTimeout the timeout to connect a rendezvous server, an unreachable server doesn't delay the others
+const bootstrapConnectTimeout = 10 * time.Second
+
+func init() {
+	Register("rendezvous", func(c *config.Config) Discoverer {
+		return NewRendezvousDiscoverer(c)
+	})
+}
+
+// Rendezvous register this device by the group name in a private dht bootstrapped from the rendezvous servers
+// and look up the other devices of the group, the devices can find each other across networks
+type Rendezvous struct {
+	cfg *config.Config
+
+	dht    *dht.IpfsDHT
+	cancel context.CancelFunc
+}
+
+func NewRendezvousDiscoverer(c *config.Config) *Rendezvous {
+	return &Rendezvous{cfg: c}
+}
+
+func (r *Rendezvous) Start(ctx context.Context, peerHost host.Host, serviceName string, logChan chan string) (<-chan peer.AddrInfo, error) {
+	bootstrapPeers, err := p2p.ParseAddrInfos(r.cfg.Discovery.Rendezvous.BootstrapPeers)
+	if err != nil {
+		return nil, err
+	}
+	if len(bootstrapPeers) == 0 {
+		return nil, xerror.NewRuntimeError("discovery.rendezvous.bootstrap_peers is empty")
+	}
+
+	interval := time.Duration(r.cfg.Discovery.Rendezvous.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	// connect the rendezvous servers before bootstrapping the dht
+	connectBootstrapPeers(ctx, peerHost, bootstrapPeers, logChan)
+
+	r.dht, err = rendezvous.NewDHT(ctx, peerHost, dht.ModeAuto, bootstrapPeers)
+	if err != nil {
+		r.cancel()
+		return nil, err
+	}
+
+	routingDiscovery := routing.NewRoutingDiscovery(r.dht)
+	// advertise until the context is done
+	util.Advertise(ctx, routingDiscovery, serviceName)
+
+	peerChan := make(chan peer.AddrInfo)
+	go r.findLoop(ctx, routingDiscovery, peerHost, serviceName, interval, peerChan, logChan)
+
+	return peerChan, nil
+}
+
+// connectBootstrapPeers connect the rendezvous servers at the same time and wait until each is connected or timed out
+func connectBootstrapPeers(ctx context.Context, peerHost host.Host, bootstrapPeers []peer.AddrInfo, logChan chan string) {
+	var wg sync.WaitGroup
+	for _, bootstrapPeer := range bootstrapPeers {
+		wg.Add(1)
+		go func(bootstrapPeer peer.AddrInfo) {
+			defer wg.Done()
+			connectCtx, cancel := context.WithTimeout(ctx, bootstrapConnectTimeout)
+			defer cancel()
+			err := peerHost.Connect(connectCtx, bootstrapPeer)
+			if err != nil {
+				logChan <- fmt.Sprintf("can not connect rendezvous server %s: %s", bootstrapPeer.ID, err)
+			}
+		}(bootstrapPeer)
+	}
+	wg.Wait()
+}
+
+func (r *Rendezvous) Stop() error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	if r.dht == nil {
+		return nil
+	}
+	return r.dht.Close()
+}
+
+// findLoop look up the devices of the group every interval and send the ones not connected to the peer channel
+func (r *Rendezvous) findLoop(ctx context.Context, routingDiscovery *routing.RoutingDiscovery, peerHost host.Host, serviceName string, interval time.Duration, peerChan chan peer.AddrInfo, logChan chan string) {
+	defer close(peerChan)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		foundChan, err := routingDiscovery.FindPeers(ctx, serviceName)
+		if err != nil {
+			logChan <- fmt.Sprintf("can not find peers from rendezvous: %s", err)
+		} else {
+			for peerInfo := range foundChan {
+				if peerInfo.ID == peerHost.ID() || len(peerInfo.Addrs) == 0 {
+					continue
+				}
+				if peerHost.Network().Connectedness(peerInfo.ID) == network.Connected {
+					continue
+				}
+				select {
+				case peerChan <- peerInfo:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
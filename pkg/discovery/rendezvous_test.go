@@ -0,0 +1,39 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+)
+
+func TestConnectBootstrapPeers(t *testing.T) {
+	mn := mocknet.New()
+	defer mn.Close()
+	hosts := make([]peer.AddrInfo, 3)
+	for i := range hosts {
+		h, err := mn.GenPeer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		hosts[i] = peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()}
+	}
+	// the last server is unreachable
+	_, err := mn.LinkPeers(hosts[0].ID, hosts[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	this := mn.Host(hosts[0].ID)
+
+	logChan := make(chan string, len(hosts))
+	connectBootstrapPeers(context.Background(), this, hosts[1:], logChan)
+
+	if this.Network().Connectedness(hosts[1].ID) != network.Connected {
+		t.Error("the reachable rendezvous server is not connected")
+	}
+	if len(logChan) != 1 {
+		t.Errorf("got %d connect errors, wanted 1 of the unreachable server", len(logChan))
+	}
+}
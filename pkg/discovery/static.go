@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+)
+
+func init() {
+	Register("static", func(c *config.Config) Discoverer {
+		return NewStaticDiscoverer(c)
+	})
+}
+
+// Static dial the peers listed in `discovery.static_peers`,
+// the peers are redialed every redial interval while they are offline
+type Static struct {
+	cfg *config.Config
+
+	cancel context.CancelFunc
+}
+
+func NewStaticDiscoverer(c *config.Config) *Static {
+	return &Static{cfg: c}
+}
+
+func (s *Static) Start(ctx context.Context, peerHost host.Host, serviceName string, logChan chan string) (<-chan peer.AddrInfo, error) {
+	peers, err := p2p.ParseAddrInfos(s.cfg.Discovery.StaticPeers)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(s.cfg.Discovery.Static.RedialInterval) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	peerChan := make(chan peer.AddrInfo)
+	go s.dialLoop(ctx, peerHost, peers, interval, peerChan)
+
+	return peerChan, nil
+}
+
+func (s *Static) Stop() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+// dialLoop send the offline static peers to the peer channel every interval
+func (s *Static) dialLoop(ctx context.Context, peerHost host.Host, peers []peer.AddrInfo, interval time.Duration, peerChan chan peer.AddrInfo) {
+	defer close(peerChan)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, peerInfo := range peers {
+			if peerInfo.ID == peerHost.ID() || peerHost.Network().Connectedness(peerInfo.ID) == network.Connected {
+				continue
+			}
+			select {
+			case peerChan <- peerInfo:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const udpBroadcastMaxPacketSize = 8192
+
+// udpBroadcastRetryInterval the interval to send a peer still not connected again, so a failed dial is retried
+const udpBroadcastRetryInterval = 30 * time.Second
+
+func init() {
+	Register("udp_broadcast", func(c *config.Config) Discoverer {
+		return NewUDPBroadcastDiscoverer(c)
+	})
+}
+
+// announcement the message broadcasted by each peer
+type announcement struct {
+	Service string        `json:"service"`
+	Peer    peer.AddrInfo `json:"peer"`
+}
+
+// UDPBroadcast discover peers by broadcasting announcements on the local network,
+// useful on networks that drop multicast traffic
+type UDPBroadcast struct {
+	cfg *config.Config
+
+	conn *net.UDPConn
+}
+
+func NewUDPBroadcastDiscoverer(c *config.Config) *UDPBroadcast {
+	return &UDPBroadcast{cfg: c}
+}
+
+func (u *UDPBroadcast) Start(ctx context.Context, peerHost host.Host, serviceName string, logChan chan string) (<-chan peer.AddrInfo, error) {
+	listenAddr := &net.UDPAddr{
+		IP:   net.ParseIP(u.cfg.Discovery.UDPBroadcast.ListenHost),
+		Port: u.cfg.Discovery.UDPBroadcast.ListenPort,
+	}
+	conn, err := net.ListenUDP("udp4", listenAddr)
+	if err != nil {
+		return nil, xerror.NewRuntimeErrorf("can not listen udp broadcast on %s", listenAddr).Wrap(err)
+	}
+	u.conn = conn
+
+	interval := time.Duration(u.cfg.Discovery.UDPBroadcast.Interval) * time.Second
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+
+	peerChan := make(chan peer.AddrInfo)
+	go u.announceLoop(ctx, conn, peerHost, serviceName, interval, logChan)
+	go u.receiveLoop(ctx, conn, peerHost, serviceName, peerChan, logChan)
+
+	return peerChan, nil
+}
+
+func (u *UDPBroadcast) Stop() error {
+	if u.conn == nil {
+		return nil
+	}
+	return u.conn.Close()
+}
+
+// announceLoop broadcast this host peer id and addresses every interval
+func (u *UDPBroadcast) announceLoop(ctx context.Context, conn *net.UDPConn, peerHost host.Host, serviceName string, interval time.Duration, logChan chan string) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		u.announce(conn, peerHost, serviceName, logChan)
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// announce send one announcement to all broadcast addresses
+func (u *UDPBroadcast) announce(conn *net.UDPConn, peerHost host.Host, serviceName string, logChan chan string) {
+	msg, err := json.Marshal(announcement{
+		Service: serviceName,
+		Peer: peer.AddrInfo{
+			ID:    peerHost.ID(),
+			Addrs: peerHost.Addrs(),
+		},
+	})
+	if err != nil {
+		logChan <- fmt.Sprintf("can not marshal udp broadcast announcement: %s", err)
+		return
+	}
+
+	for _, ip := range broadcastIPs() {
+		_, err := conn.WriteToUDP(msg, &net.UDPAddr{IP: ip, Port: u.cfg.Discovery.UDPBroadcast.ListenPort})
+		if err != nil {
+			logChan <- fmt.Sprintf("can not send udp broadcast to %s: %s", ip, err)
+		}
+	}
+}
+
+// receiveLoop read announcements from other peers and send the ones not connected yet to the peer channel
+func (u *UDPBroadcast) receiveLoop(ctx context.Context, conn *net.UDPConn, peerHost host.Host, serviceName string, peerChan chan peer.AddrInfo, logChan chan string) {
+	defer close(peerChan)
+
+	buffer := make([]byte, udpBroadcastMaxPacketSize)
+	discovered := make(announcedPeers)
+
+	for {
+		n, _, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if ctx.Err() == nil {
+				logChan <- fmt.Sprintf("stop udp broadcast discovery: %s", err)
+			}
+			return
+		}
+
+		var a announcement
+		err = json.Unmarshal(buffer[:n], &a)
+		if err != nil {
+			// not our message, ignore it
+			continue
+		}
+
+		if a.Service != serviceName || a.Peer.ID == peerHost.ID() || a.Peer.ID == "" {
+			continue
+		}
+
+		// announcements are repeated every interval, avoid dialing a peer that already connected,
+		// it's sent again after it disconnected or every retry interval while it's not connected
+		if peerHost.Network().Connectedness(a.Peer.ID) == network.Connected {
+			delete(discovered, a.Peer.ID)
+			continue
+		}
+
+		if !discovered.update(a.Peer, time.Now()) {
+			continue
+		}
+		logChan <- fmt.Sprintf("discovered peer by udp broadcast: %s", a.Peer)
+		select {
+		case peerChan <- a.Peer:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// announcedPeers the peers not connected sent to the peer channel
+type announcedPeers map[peer.ID]announcedPeer
+
+// announcedPeer the addresses last announced by the peer and when it's sent
+type announcedPeer struct {
+	addrs  string
+	sentAt time.Time
+}
+
+// update record the addresses of the peer, returns true if the peer is first seen, its addresses changed
+// or it's not sent for the retry interval
+func (p announcedPeers) update(info peer.AddrInfo, now time.Time) bool {
+	addrs := make([]string, len(info.Addrs))
+	for i, addr := range info.Addrs {
+		addrs[i] = addr.String()
+	}
+	sort.Strings(addrs)
+	key := strings.Join(addrs, " ")
+
+	if last, ok := p[info.ID]; ok && last.addrs == key && now.Sub(last.sentAt) < udpBroadcastRetryInterval {
+		return false
+	}
+	p[info.ID] = announcedPeer{addrs: key, sentAt: now}
+	return true
+}
+
+// broadcastIPs returns the limited broadcast address and the directed broadcast address of each ipv4 interface
+func broadcastIPs() []net.IP {
+	ips := []net.IP{net.IPv4bcast}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ips
+	}
+
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagBroadcast == 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			ip := ipNet.IP.To4()
+			if ip == nil || ip.IsLoopback() {
+				continue
+			}
+			bcast := make(net.IP, net.IPv4len)
+			for i := range ip {
+				bcast[i] = ip[i] | ^ipNet.Mask[len(ipNet.Mask)-net.IPv4len+i]
+			}
+			ips = append(ips, bcast)
+		}
+	}
+
+	return ips
+}
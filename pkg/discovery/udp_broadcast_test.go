@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+func TestAnnouncedPeersUpdate(t *testing.T) {
+	addrA := multiaddr.StringCast("/ip4/192.168.1.2/tcp/4001")
+	addrB := multiaddr.StringCast("/ip4/192.168.1.3/tcp/4001")
+
+	tests := []struct {
+		name      string
+		announced []peer.AddrInfo
+		// after the time since the first announcement of each announcement, zero if not set
+		after []time.Duration
+		want  []bool
+	}{
+		{
+			name: "repeated announcement",
+			announced: []peer.AddrInfo{
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+			},
+			want: []bool{true, false},
+		},
+		{
+			name: "addresses in another order",
+			announced: []peer.AddrInfo{
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA, addrB}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrB, addrA}},
+			},
+			want: []bool{true, false},
+		},
+		{
+			name: "addresses changed",
+			announced: []peer.AddrInfo{
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrB}},
+			},
+			want: []bool{true, true},
+		},
+		{
+			name: "not connected after the retry interval",
+			announced: []peer.AddrInfo{
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+			},
+			after: []time.Duration{0, udpBroadcastRetryInterval / 2, udpBroadcastRetryInterval, udpBroadcastRetryInterval + time.Second},
+			want:  []bool{true, false, true, false},
+		},
+		{
+			name: "another peer",
+			announced: []peer.AddrInfo{
+				{ID: peer.ID("a"), Addrs: []multiaddr.Multiaddr{addrA}},
+				{ID: peer.ID("b"), Addrs: []multiaddr.Multiaddr{addrA}},
+			},
+			want: []bool{true, true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := time.Now()
+			discovered := make(announcedPeers)
+			for i, info := range test.announced {
+				now := start
+				if test.after != nil {
+					now = start.Add(test.after[i])
+				}
+				if got := discovered.update(info, now); got != test.want[i] {
+					t.Errorf("announcement %d: got %v, wanted %v", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// ErrTooLarge the files are larger than the max size
+var ErrTooLarge = errors.New("files larger than the max size")
+
+// ParseURIList returns the local paths of the file uris in the uri list, or false if any uri isn't a local file
+func ParseURIList(uriList []byte) ([]string, bool) {
+	paths := []string{}
+	for _, line := range strings.Split(string(uriList), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		u, err := url.Parse(line)
+		if err != nil || u.Scheme != "file" || (u.Host != "" && u.Host != "localhost") {
+			return nil, false
+		}
+		paths = append(paths, filepath.FromSlash(u.Path))
+	}
+	return paths, len(paths) > 0
+}
+
+// URIList returns the uri list of the local paths
+func URIList(paths []string) []byte {
+	var b bytes.Buffer
+	for _, p := range paths {
+		u := url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+		b.WriteString(u.String())
+		b.WriteString("\r\n")
+	}
+	return b.Bytes()
+}
+
+// Pack write the tar archive of the files and directories to w without keeping it in memory, returns the size of the archive,
+// each path is at the top level of the archive, symlinks and special files are skipped, the archive larger than max size is refused
+func Pack(w io.Writer, paths []string, maxSize int) (int64, error) {
+	lw := &limitWriter{w: w, limit: int64(maxSize)}
+	tw := tar.NewWriter(lw)
+	total := 0
+
+	for _, root := range paths {
+		base := filepath.Base(root)
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && !d.Type().IsRegular() {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			name := path.Join(base, filepath.ToSlash(rel))
+
+			header, err := tar.FileInfoHeader(info, "")
+			if err != nil {
+				return err
+			}
+			header.Name = name
+			if d.IsDir() {
+				header.Name += "/"
+			}
+			// the owner of the sender means nothing to the receiver
+			header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+
+			// refuse before writing the files larger than max size
+			total += int(info.Size())
+			if total > maxSize {
+				return ErrTooLarge
+			}
+
+			err = tw.WriteHeader(header)
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			_, err = io.Copy(tw, f)
+			return err
+		})
+		if errors.Is(err, ErrTooLarge) {
+			return 0, ErrTooLarge
+		}
+		if err != nil {
+			return 0, xerror.NewRuntimeErrorf("can not pack %s", root).Wrap(err)
+		}
+	}
+
+	// the archive is larger than the files by the headers
+	err := tw.Close()
+	if errors.Is(err, ErrTooLarge) {
+		return 0, ErrTooLarge
+	}
+	if err != nil {
+		return 0, xerror.NewRuntimeError("can not pack files").Wrap(err)
+	}
+	return lw.written, nil
+}
+
+// limitWriter write to w until the limit, more data fails with ErrTooLarge
+type limitWriter struct {
+	w       io.Writer
+	limit   int64
+	written int64
+}
+
+func (lw *limitWriter) Write(p []byte) (int, error) {
+	if lw.written+int64(len(p)) > lw.limit {
+		return 0, ErrTooLarge
+	}
+	n, err := lw.w.Write(p)
+	lw.written += int64(n)
+	return n, err
+}
+
+// Unpack extract the tar archive read from r to the directory, returns the paths of the top level files and directories,
+// a top level name already in the directory is renamed to keep the existing one,
+// the extracted files are removed when the archive is invalid
+func Unpack(r io.Reader, dir string, maxSize int) (paths []string, err error) {
+	err = os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not create download directory").Wrap(err)
+	}
+
+	defer func() {
+		if err != nil {
+			for _, p := range paths {
+				os.RemoveAll(p)
+			}
+			paths = nil
+		}
+	}()
+
+	tr := tar.NewReader(r)
+	topLevel := map[string]string{} // name in the archive to the name in the directory
+	total := int64(0)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return paths, xerror.NewRuntimeError("can not read files archive").Wrap(err)
+		}
+
+		name, err := cleanName(header.Name)
+		if err != nil {
+			return paths, err
+		}
+		top, rest, _ := strings.Cut(name, "/")
+		localTop, ok := topLevel[top]
+		if !ok {
+			localTop = uniqueName(dir, top)
+			topLevel[top] = localTop
+			paths = append(paths, filepath.Join(dir, localTop))
+		}
+		target := filepath.Join(dir, localTop, filepath.FromSlash(rest))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+			if err != nil {
+				return paths, xerror.NewRuntimeErrorf("can not create directory %s", target).Wrap(err)
+			}
+		case tar.TypeReg:
+			total += header.Size
+			if total > int64(maxSize) {
+				return paths, ErrTooLarge
+			}
+			err = writeFile(target, tr, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return paths, err
+			}
+		default:
+			// links and special files are never packed
+			return paths, xerror.NewRuntimeErrorf("unsupported file type %q of %s", header.Typeflag, header.Name)
+		}
+	}
+	return paths, nil
+}
+
+// cleanName returns the slash separated relative name of the archive entry, the name escaping the directory is refused
+func cleanName(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimSuffix(name, "/"))
+	if cleaned == "." || path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") ||
+		strings.Contains(cleaned, "\\") || filepath.VolumeName(cleaned) != "" {
+		return "", xerror.NewRuntimeErrorf("invalid file name %q in files archive", name)
+	}
+	return cleaned, nil
+}
+
+// uniqueName returns the name, or the name with a number if it's already in the directory
+func uniqueName(dir string, name string) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 1; ; i++ {
+		_, err := os.Lstat(filepath.Join(dir, candidate))
+		if os.IsNotExist(err) {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s (%d)%s", stem, i, ext)
+	}
+}
+
+func writeFile(target string, r io.Reader, perm fs.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return xerror.NewRuntimeErrorf("can not create directory of %s", target).Wrap(err)
+	}
+	// the sender can not make the file executable by others or setuid
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_EXCL|os.O_WRONLY, perm&0755)
+	if err != nil {
+		return xerror.NewRuntimeErrorf("can not create file %s", target).Wrap(err)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return xerror.NewRuntimeErrorf("can not write file %s", target).Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package files
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseURIList(t *testing.T) {
+	tests := []struct {
+		name      string
+		uriList   string
+		wantPaths []string
+		wantOK    bool
+	}{
+		{name: "files", uriList: "file:///home/a.txt\r\nfile:///home/b%20c.txt\r\n", wantPaths: []string{"/home/a.txt", "/home/b c.txt"}, wantOK: true},
+		{name: "localhost and comment", uriList: "# copied\nfile://localhost/home/a.txt", wantPaths: []string{"/home/a.txt"}, wantOK: true},
+		{name: "remote uri", uriList: "file:///home/a.txt\nhttps://example.com/a.txt", wantOK: false},
+		{name: "remote host", uriList: "file://server/share/a.txt", wantOK: false},
+		{name: "text", uriList: "hello world", wantOK: false},
+		{name: "empty", uriList: "", wantOK: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			paths, ok := ParseURIList([]byte(test.uriList))
+			if ok != test.wantOK {
+				t.Fatalf("got ok %v, wanted %v", ok, test.wantOK)
+			}
+			if ok && !reflect.DeepEqual(paths, test.wantPaths) {
+				t.Errorf("got paths %q, wanted %q", paths, test.wantPaths)
+			}
+		})
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	src := t.TempDir()
+	writeTestFile(t, filepath.Join(src, "note.txt"), "note")
+	writeTestFile(t, filepath.Join(src, "dir", "a.txt"), "a")
+	writeTestFile(t, filepath.Join(src, "dir", "sub", "b.txt"), "b")
+	err := os.Symlink(filepath.Join(src, "note.txt"), filepath.Join(src, "dir", "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	size, err := Pack(&archive, []string{filepath.Join(src, "note.txt"), filepath.Join(src, "dir")}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != int64(archive.Len()) {
+		t.Errorf("got size %d, wanted the archive size %d", size, archive.Len())
+	}
+
+	dst := t.TempDir()
+	// the existing file is kept
+	writeTestFile(t, filepath.Join(dst, "note.txt"), "existing")
+
+	paths, err := Unpack(&archive, dst, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantPaths := []string{filepath.Join(dst, "note (1).txt"), filepath.Join(dst, "dir")}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("got paths %q, wanted %q", paths, wantPaths)
+	}
+
+	wantFiles := map[string]string{
+		"note.txt":      "existing",
+		"note (1).txt":  "note",
+		"dir/a.txt":     "a",
+		"dir/sub/b.txt": "b",
+	}
+	for name, want := range wantFiles {
+		b, err := os.ReadFile(filepath.Join(dst, filepath.FromSlash(name)))
+		if err != nil {
+			t.Errorf("can not read %s: %v", name, err)
+			continue
+		}
+		if string(b) != want {
+			t.Errorf("got %s content %q, wanted %q", name, b, want)
+		}
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "dir", "link")); !os.IsNotExist(err) {
+		t.Errorf("symlink is extracted: %v", err)
+	}
+}
+
+func TestPackTooLarge(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+	}{
+		{name: "file larger than max size", size: 2048},
+		{name: "archive with the headers larger than max size", size: 1000},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			src := t.TempDir()
+			writeTestFile(t, filepath.Join(src, "big.txt"), string(make([]byte, test.size)))
+
+			_, err := Pack(io.Discard, []string{filepath.Join(src, "big.txt")}, 1024)
+			if !errors.Is(err, ErrTooLarge) {
+				t.Errorf("got error %v, wanted %v", err, ErrTooLarge)
+			}
+		})
+	}
+}
+
+func TestUnpackRefusesUnsafeArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  tar.Header
+		content string
+		maxSize int
+		wantErr error
+	}{
+		{name: "parent directory", header: tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg}, content: "x", maxSize: 1024},
+		{name: "nested parent directory", header: tar.Header{Name: "a/../../evil.txt", Typeflag: tar.TypeReg}, content: "x", maxSize: 1024},
+		{name: "absolute path", header: tar.Header{Name: "/tmp/evil.txt", Typeflag: tar.TypeReg}, content: "x", maxSize: 1024},
+		{name: "symlink", header: tar.Header{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}, maxSize: 1024},
+		{name: "too large", header: tar.Header{Name: "big.txt", Typeflag: tar.TypeReg}, content: "0123456789", maxSize: 5, wantErr: ErrTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			header := test.header
+			header.Mode = 0644
+			header.Size = int64(len(test.content))
+			if err := tw.WriteHeader(&header); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := tw.Write([]byte(test.content)); err != nil {
+				t.Fatal(err)
+			}
+			if err := tw.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			root := t.TempDir()
+			dst := filepath.Join(root, "downloads")
+			paths, err := Unpack(&buf, dst, test.maxSize)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, wanted %v", err, test.wantErr)
+			}
+			if paths != nil {
+				t.Errorf("got paths %q, wanted none", paths)
+			}
+
+			// nothing is left outside or inside the download directory
+			entries, _ := os.ReadDir(dst)
+			if len(entries) != 0 {
+				t.Errorf("got %d entries in download directory", len(entries))
+			}
+			rootEntries, _ := os.ReadDir(root)
+			if len(rootEntries) != 1 {
+				t.Errorf("got %d entries next to download directory", len(rootEntries))
+			}
+		})
+	}
+}
+
+func writeTestFile(t *testing.T, name string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
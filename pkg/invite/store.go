@@ -0,0 +1,111 @@
+package invite
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/utils/stringutil"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const invitesFileName = "invites.json"
+
+// redemption the device which redeemed a token
+type redemption struct {
+	PeerID    string `json:"peerId"`
+	ExpiresAt int64  `json:"expiresAt"`
+	Token     *Token `json:"token,omitempty"` // kept for the issuer to vouch for the device
+}
+
+// Store record the redeemed tokens, a token can only be redeemed by one device,
+// each group member records the token it accepted by itself or by the vouch of the issuer
+type Store struct {
+	filePath    string
+	redemptions map[string]redemption
+
+	mu sync.Mutex
+}
+
+// NewStore load the redeemed tokens from the invites file in the config directory
+func NewStore(configDirPath string) (*Store, error) {
+	s := &Store{
+		filePath:    stringutil.JoinURL(configDirPath, invitesFileName),
+		redemptions: make(map[string]redemption),
+	}
+
+	b, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, xerror.NewRuntimeError("can not read invites file").Wrap(err)
+	}
+
+	err = json.Unmarshal(b, &s.redemptions)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not unmarshal invites json").Wrap(err)
+	}
+	return s, nil
+}
+
+// Redeem record the token is redeemed by the peer, it fails if the token was redeemed by another peer.
+// the same peer can redeem the token again, e.g. when it reconnects
+func (s *Store) Redeem(t *Token, peerID string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired(now)
+
+	if r, ok := s.redemptions[t.ID]; ok && r.PeerID != peerID {
+		return xerror.NewRuntimeErrorf("invite token %s was already redeemed by %s", t.ID, r.PeerID)
+	}
+	s.redemptions[t.ID] = redemption{
+		PeerID:    peerID,
+		ExpiresAt: t.ExpiresAt,
+		Token:     t,
+	}
+
+	return s.save()
+}
+
+// Redeemed returns the tokens of the issuer redeemed and not expired by the peer id of the device which redeemed it
+func (s *Store) Redeemed(issuer string, now time.Time) map[string]*Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeExpired(now)
+
+	tokens := make(map[string]*Token)
+	for _, r := range s.redemptions {
+		if r.Token != nil && r.Token.Issuer == issuer {
+			tokens[r.PeerID] = r.Token
+		}
+	}
+	return tokens
+}
+
+// removeExpired remove the expired tokens, they can't be verified anymore
+func (s *Store) removeExpired(now time.Time) {
+	for id, r := range s.redemptions {
+		if now.Unix() > r.ExpiresAt {
+			delete(s.redemptions, id)
+		}
+	}
+}
+
+// save write the redeemed tokens to the invites file
+func (s *Store) save() error {
+	b, err := json.MarshalIndent(s.redemptions, "", "  ")
+	if err != nil {
+		return xerror.NewRuntimeError("can not marshal invites").Wrap(err)
+	}
+
+	err = os.WriteFile(s.filePath, b, 0644)
+	if err != nil {
+		return xerror.NewRuntimeError("can not write invites file").Wrap(err)
+	}
+	return nil
+}
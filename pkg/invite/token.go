@@ -0,0 +1,120 @@
+package invite
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	p2pcrypto "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const tokenIDSize int = 16
+
+// Token a time-limited, single-use invitation for adding a new device to the group,
+// it's signed by the issuing device and the group members trusting the issuer trust the new device
+type Token struct {
+	ID        string   `json:"id"`
+	Group     string   `json:"group"`
+	Issuer    string   `json:"issuer"` // peer id of the issuing device
+	Addrs     []string `json:"addrs"`  // rendezvous addresses to connect the issuing device
+	ExpiresAt int64    `json:"exp"`    // unix time
+	Signature []byte   `json:"sig,omitempty"`
+}
+
+// NewToken create a token for the group signed by the device id private key
+func NewToken(privKey p2pcrypto.PrivKey, group string, addrs []string, ttl time.Duration) (*Token, error) {
+	issuer, err := peer.IDFromPrivateKey(privKey)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not get peer id from private key").Wrap(err)
+	}
+
+	id := make([]byte, tokenIDSize)
+	_, err = rand.Read(id)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not generate token id").Wrap(err)
+	}
+
+	t := &Token{
+		ID:        hex.EncodeToString(id),
+		Group:     group,
+		Issuer:    issuer.String(),
+		Addrs:     addrs,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}
+
+	t.Signature, err = privKey.Sign(t.signingBytes())
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not sign token").Wrap(err)
+	}
+	return t, nil
+}
+
+// DecodeToken decode the token string, the token is not verified
+func DecodeToken(s string) (*Token, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("invite token is not base64 encoded").Wrap(err)
+	}
+
+	t := &Token{}
+	err = json.Unmarshal(b, t)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not unmarshal invite token").Wrap(err)
+	}
+	return t, nil
+}
+
+// Encode encode the token to a string which can be copied or shown as a QR code
+func (t *Token) Encode() (string, error) {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return "", xerror.NewRuntimeError("can not marshal invite token").Wrap(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssuerID returns the peer id of the issuing device
+func (t *Token) IssuerID() (peer.ID, error) {
+	return peer.Decode(t.Issuer)
+}
+
+// Verify verify the token is for the group, not expired and signed by the issuer
+func (t *Token) Verify(group string, now time.Time) error {
+	if t.Group != group {
+		return xerror.NewRuntimeErrorf("invite token is for group %s", t.Group)
+	}
+	if now.Unix() > t.ExpiresAt {
+		return xerror.NewRuntimeErrorf("invite token expired at %s", time.Unix(t.ExpiresAt, 0))
+	}
+
+	issuer, err := t.IssuerID()
+	if err != nil {
+		return xerror.NewRuntimeError("invalid invite token issuer").Wrap(err)
+	}
+	pubKey, err := issuer.ExtractPublicKey()
+	if err != nil {
+		return xerror.NewRuntimeError("can not get public key of invite token issuer").Wrap(err)
+	}
+
+	ok, err := pubKey.Verify(t.signingBytes(), t.Signature)
+	if err != nil {
+		return xerror.NewRuntimeError("can not verify invite token signature").Wrap(err)
+	}
+	if !ok {
+		return xerror.NewRuntimeError("invalid invite token signature")
+	}
+	return nil
+}
+
+// signingBytes returns the bytes signed by the issuer, all fields except the signature
+func (t *Token) signingBytes() []byte {
+	unsigned := *t
+	unsigned.Signature = nil
+	// marshaling a struct of strings can't fail
+	b, _ := json.Marshal(unsigned)
+	return b
+}
@@ -0,0 +1,161 @@
+package invite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+)
+
+func TestToken(t *testing.T) {
+	privKey, _, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		modify  func(token *Token)
+		group   string
+		now     time.Time
+		wantErr bool
+	}{
+		{
+			name:  "valid token",
+			group: "default",
+			now:   now,
+		},
+		{
+			name:    "wrong group",
+			group:   "office",
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			group:   "default",
+			now:     now.Add(2 * time.Hour),
+			wantErr: true,
+		},
+		{
+			name:    "tampered address",
+			modify:  func(token *Token) { token.Addrs = []string{"/ip4/6.6.6.6/tcp/4001"} },
+			group:   "default",
+			now:     now,
+			wantErr: true,
+		},
+		{
+			name: "signed by another device",
+			modify: func(token *Token) {
+				other, err := NewToken(otherKey, "default", token.Addrs, time.Hour)
+				if err != nil {
+					t.Fatal(err)
+				}
+				token.Signature = other.Signature
+			},
+			group:   "default",
+			now:     now,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := NewToken(privKey, "default", []string{"/ip4/10.1.2.3/tcp/4001"}, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.modify != nil {
+				test.modify(token)
+			}
+
+			// verify after encoding and decoding like the token is sent to another device
+			s, err := token.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := DecodeToken(s)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = decoded.Verify(test.group, test.now)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestStoreRedeem(t *testing.T) {
+	now := time.Now()
+	token := &Token{ID: "token", ExpiresAt: now.Add(time.Hour).Unix()}
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Redeem(token, "peer-a", now); err != nil {
+		t.Fatalf("first redeem error = %v", err)
+	}
+	if err := store.Redeem(token, "peer-a", now); err != nil {
+		t.Fatalf("redeem again by the same peer error = %v", err)
+	}
+	if err := store.Redeem(token, "peer-b", now); err == nil {
+		t.Fatal("redeem by another peer should return error")
+	}
+}
+
+func TestStoreLoad(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	token := &Token{ID: "token", ExpiresAt: now.Add(time.Hour).Unix()}
+
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Redeem(token, "peer-a", now); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := loaded.Redeem(token, "peer-b", now); err == nil {
+		t.Fatal("redeem a loaded token by another peer should return error")
+	}
+}
+
+func TestStoreRedeemed(t *testing.T) {
+	now := time.Now()
+	tokens := []*Token{
+		{ID: "issued", Issuer: "issuer", ExpiresAt: now.Add(time.Hour).Unix()},
+		{ID: "other issuer", Issuer: "other", ExpiresAt: now.Add(time.Hour).Unix()},
+		{ID: "expired", Issuer: "issuer", ExpiresAt: now.Add(time.Minute).Unix()},
+	}
+
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, token := range tokens {
+		if err := store.Redeem(token, fmt.Sprintf("peer-%d", i), now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	redeemed := store.Redeemed("issuer", now.Add(30*time.Minute))
+	if len(redeemed) != 1 || redeemed["peer-0"] != tokens[0] {
+		t.Errorf("got %v, wanted the token issued by issuer redeemed by peer-0", redeemed)
+	}
+}
@@ -0,0 +1,114 @@
+package p2p
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/p2p/transport/tcp"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// NewHost create a libp2p host listening on the tcp address with the identity and network options in config,
+// the extra options are appended after the options from config
+func NewHost(cfg *config.Config, listenHost string, listenPort int, opts ...libp2p.Option) (host.Host, error) {
+	// 0.0.0.0 will listen on any interface device.
+	listenAddr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/ip4/%s/tcp/%d", listenHost, listenPort))
+	if err != nil {
+		return nil, xerror.NewFatalError("error to multiaddr.NewMultiaddr").Wrap(err)
+	}
+
+	networkOpts, err := networkOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	hostOpts := []libp2p.Option{
+		libp2p.ListenAddrs(listenAddr),
+		libp2p.Identity(cfg.ID),
+	}
+	hostOpts = append(hostOpts, networkOpts...)
+	hostOpts = append(hostOpts, opts...)
+
+	// libp2p.New constructs a new libp2p Host.
+	h, err := libp2p.New(hostOpts...)
+	if err != nil {
+		return nil, xerror.NewFatalError("error to libp2p.New").Wrap(err)
+	}
+	return h, nil
+}
+
+// networkOptions returns the private network, NAT traversal and relay client options enabled in config
+func networkOptions(cfg *config.Config) ([]libp2p.Option, error) {
+	opts := []libp2p.Option{}
+
+	if cfg.Network.PSK != "" {
+		psk, err := crypto.DecodePSK(cfg.Network.PSK)
+		if err != nil {
+			return nil, xerror.NewFatalError("invalid network.psk").Wrap(err)
+		}
+		// peers without the key can't complete the connection handshake,
+		// the private network only supports tcp transport
+		opts = append(opts,
+			libp2p.PrivateNetwork(psk),
+			libp2p.Transport(tcp.NewTCPTransport),
+		)
+	}
+
+	if cfg.Network.NATPortMap {
+		opts = append(opts, libp2p.NATPortMap())
+	}
+
+	if cfg.Network.HolePunching {
+		opts = append(opts, libp2p.EnableHolePunching())
+	}
+
+	if cfg.Network.RelayClient {
+		relays, err := ParseAddrInfos(cfg.Network.StaticRelays)
+		if err != nil {
+			return nil, xerror.NewFatalError("invalid network.static_relays").Wrap(err)
+		}
+		if len(relays) == 0 {
+			return nil, xerror.NewFatalError("relay client is enabled but network.static_relays is empty")
+		}
+		opts = append(opts,
+			libp2p.EnableRelay(),
+			libp2p.EnableAutoRelayWithStaticRelays(relays),
+			// the group is too small for AutoNAT to be sure, always reserve a slot on the relays
+			libp2p.ForceReachabilityPrivate(),
+		)
+	}
+
+	return opts, nil
+}
+
+// ParseAddrInfos parse full multiaddrs with `/p2p/<id>` and group the addresses by peer
+func ParseAddrInfos(addrs []string) ([]peer.AddrInfo, error) {
+	maddrs := make([]multiaddr.Multiaddr, 0, len(addrs))
+	for _, addr := range addrs {
+		maddr, err := multiaddr.NewMultiaddr(addr)
+		if err != nil {
+			return nil, xerror.NewRuntimeErrorf("invalid peer multiaddr %s", addr).Wrap(err)
+		}
+		maddrs = append(maddrs, maddr)
+	}
+
+	peers, err := peer.AddrInfosFromP2pAddrs(maddrs...)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("invalid peer multiaddr").Wrap(err)
+	}
+	return peers, nil
+}
+
+// FullAddrs returns the addresses of the host with `/p2p/<id>` which can be dialed by other peers
+func FullAddrs(h host.Host) []multiaddr.Multiaddr {
+	addrs, err := peer.AddrInfoToP2pAddrs(&peer.AddrInfo{ID: h.ID(), Addrs: h.Addrs()})
+	if err != nil {
+		return nil
+	}
+	return addrs
+}
@@ -0,0 +1,59 @@
+package p2p
+
+import (
+	"testing"
+)
+
+func TestParseAddrInfos(t *testing.T) {
+	tests := []struct {
+		name      string
+		addrs     []string
+		wantPeers int
+		wantAddrs int
+		wantErr   bool
+	}{
+		{
+			name:      "one peer",
+			addrs:     []string{"/ip4/10.1.2.3/tcp/4001/p2p/QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N"},
+			wantPeers: 1,
+			wantAddrs: 1,
+		},
+		{
+			name: "group addresses of the same peer",
+			addrs: []string{
+				"/ip4/10.1.2.3/tcp/4001/p2p/QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N",
+				"/ip4/192.168.1.3/tcp/4001/p2p/QmYyQSo1c1Ym7orWxLYvCrM2EmxFTANf8wXmmE7DWjhx5N",
+			},
+			wantPeers: 1,
+			wantAddrs: 2,
+		},
+		{
+			name:    "missing peer id",
+			addrs:   []string{"/ip4/10.1.2.3/tcp/4001"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid multiaddr",
+			addrs:   []string{"10.1.2.3:4001"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseAddrInfos(test.addrs)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseAddrInfos() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+			if len(got) != test.wantPeers {
+				t.Fatalf("got %d peers, want %d", len(got), test.wantPeers)
+			}
+			if len(got[0].Addrs) != test.wantAddrs {
+				t.Fatalf("got %d addrs, want %d", len(got[0].Addrs), test.wantAddrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"time"
+
+	"github.com/libp2p/go-libp2p"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	relayv2 "github.com/libp2p/go-libp2p/p2p/protocol/circuitv2/relay"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// Relay a circuit relay v2 node for the group, it relays the traffic between devices
+// that can't connect directly and doesn't sync the clipboard itself
+type Relay struct {
+	Host host.Host
+}
+
+// NewRelay create and start a relay node listening on `network.relay.listen_host` and `network.relay.listen_port`,
+// only the devices of the group can reserve a slot and connect through it
+func NewRelay(cfg *config.Config) (*Relay, error) {
+	acl, err := newGroupACL(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// a relay node is public, it doesn't reserve slots on other relays
+	relayCfg := *cfg
+	relayCfg.Network.RelayClient = false
+
+	h, err := p2p.NewHost(
+		&relayCfg,
+		cfg.Network.Relay.ListenHost,
+		cfg.Network.Relay.ListenPort,
+		libp2p.EnableRelayService(relayv2.WithResources(resources(cfg.Network.Relay)), relayv2.WithACL(acl)),
+		libp2p.ForceReachabilityPublic(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Relay{Host: h}, nil
+}
+
+// Close stop the relay node
+func (r *Relay) Close() error {
+	return r.Host.Close()
+}
+
+// resources returns the default resources of the relay service with the limits of the config,
+// clipboard payloads are much bigger than the default relayed data limit
+func resources(cfg config.RelayConfig) relayv2.Resources {
+	rc := relayv2.DefaultResources()
+	if cfg.MaxReservations > 0 {
+		rc.MaxReservations = cfg.MaxReservations
+	}
+	if cfg.LimitDuration > 0 {
+		rc.Limit.Duration = time.Duration(cfg.LimitDuration) * time.Second
+	}
+	if cfg.LimitData > 0 {
+		rc.Limit.Data = cfg.LimitData
+	}
+	return rc
+}
+
+// groupACL allow the devices of the group to use the relay, all peers connected to the relay are in the group
+// with the pre-shared key, otherwise the peer must be in `network.relay.allowed_peers`
+type groupACL struct {
+	psk     bool
+	allowed map[peer.ID]struct{}
+}
+
+// newGroupACL create the acl of the group, returns an error if there is neither a psk nor allowed peers
+// because anyone could use the relay
+func newGroupACL(cfg *config.Config) (*groupACL, error) {
+	acl := &groupACL{
+		psk:     cfg.Network.PSK != "",
+		allowed: make(map[peer.ID]struct{}, len(cfg.Network.Relay.AllowedPeers)),
+	}
+	for _, id := range cfg.Network.Relay.AllowedPeers {
+		peerID, err := peer.Decode(id)
+		if err != nil {
+			return nil, xerror.NewRuntimeErrorf("invalid peer id %q in network.relay.allowed_peers", id).Wrap(err)
+		}
+		acl.allowed[peerID] = struct{}{}
+	}
+
+	if !acl.psk && len(acl.allowed) == 0 {
+		return nil, xerror.NewRuntimeError("relay requires network.psk or network.relay.allowed_peers to restrict it to the group")
+	}
+	return acl, nil
+}
+
+// isGroupPeer returns true if the peer is a device of the group
+func (acl *groupACL) isGroupPeer(p peer.ID) bool {
+	if acl.psk {
+		return true
+	}
+	_, ok := acl.allowed[p]
+	return ok
+}
+
+func (acl *groupACL) AllowReserve(p peer.ID, _ multiaddr.Multiaddr) bool {
+	return acl.isGroupPeer(p)
+}
+
+func (acl *groupACL) AllowConnect(src peer.ID, _ multiaddr.Multiaddr, dest peer.ID) bool {
+	return acl.isGroupPeer(src) && acl.isGroupPeer(dest)
+}
@@ -0,0 +1,82 @@
+package relay
+
+import (
+	"testing"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+)
+
+func TestGroupACL(t *testing.T) {
+	newPeerID := func() peer.ID {
+		key, _, err := crypto.NewKeyPair()
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := peer.IDFromPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	member := newPeerID()
+	other := newPeerID()
+
+	tests := []struct {
+		name        string
+		psk         string
+		allowed     []string
+		wantErr     bool
+		wantMember  bool
+		wantOther   bool
+		wantConnect bool
+	}{
+		{
+			name:        "private network",
+			psk:         "key",
+			wantMember:  true,
+			wantOther:   true,
+			wantConnect: true,
+		},
+		{
+			name:       "allowed peers",
+			allowed:    []string{member.String()},
+			wantMember: true,
+		},
+		{
+			name:    "neither psk nor allowed peers",
+			wantErr: true,
+		},
+		{
+			name:    "invalid allowed peer",
+			allowed: []string{"not a peer id"},
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Network.PSK = test.psk
+			cfg.Network.Relay.AllowedPeers = test.allowed
+
+			acl, err := newGroupACL(cfg)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := acl.AllowReserve(member, nil); got != test.wantMember {
+				t.Errorf("got member reserve %v, wanted %v", got, test.wantMember)
+			}
+			if got := acl.AllowReserve(other, nil); got != test.wantOther {
+				t.Errorf("got other reserve %v, wanted %v", got, test.wantOther)
+			}
+			if got := acl.AllowConnect(other, nil, member); got != test.wantConnect {
+				t.Errorf("got connect from other %v, wanted %v", got, test.wantConnect)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package rendezvous
+
+import (
+	"context"
+
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// ProtocolPrefix the prefix of the private kademlia dht protocol, it doesn't join the public IPFS dht
+const ProtocolPrefix protocol.ID = "/cross-clipboard"
+
+// NewDHT create a private kademlia dht bootstrapped from the giving peers,
+// it only stores provider records which are used to find the devices of a group
+func NewDHT(ctx context.Context, h host.Host, mode dht.ModeOpt, bootstrapPeers []peer.AddrInfo) (*dht.IpfsDHT, error) {
+	kadDHT, err := dht.New(
+		ctx,
+		h,
+		dht.Mode(mode),
+		dht.ProtocolPrefix(ProtocolPrefix),
+		dht.DisableValues(),
+		dht.BootstrapPeers(bootstrapPeers...),
+	)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error to create dht").Wrap(err)
+	}
+
+	err = kadDHT.Bootstrap(ctx)
+	if err != nil {
+		kadDHT.Close()
+		return nil, xerror.NewRuntimeError("error to bootstrap dht").Wrap(err)
+	}
+
+	return kadDHT, nil
+}
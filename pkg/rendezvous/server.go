@@ -0,0 +1,55 @@
+package rendezvous
+
+import (
+	"context"
+
+	"github.com/libp2p/go-libp2p"
+	dht "github.com/libp2p/go-libp2p-kad-dht"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+)
+
+// Server a self-hosted rendezvous point, a dht server node which the devices bootstrap from
+// to register and look up the devices of their group, it doesn't sync the clipboard itself
+type Server struct {
+	Host host.Host
+	DHT  *dht.IpfsDHT
+}
+
+// NewServer create and start a rendezvous server listening on `discovery.rendezvous.server.listen_host` and `listen_port`,
+// the other bootstrap peers in config are connected to share the records
+func NewServer(ctx context.Context, cfg *config.Config) (*Server, error) {
+	h, err := p2p.NewHost(
+		cfg,
+		cfg.Discovery.Rendezvous.Server.ListenHost,
+		cfg.Discovery.Rendezvous.Server.ListenPort,
+		libp2p.ForceReachabilityPublic(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	bootstrapPeers, err := p2p.ParseAddrInfos(cfg.Discovery.Rendezvous.BootstrapPeers)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	kadDHT, err := NewDHT(ctx, h, dht.ModeServer, bootstrapPeers)
+	if err != nil {
+		h.Close()
+		return nil, err
+	}
+
+	return &Server{Host: h, DHT: kadDHT}, nil
+}
+
+// Close stop the rendezvous server
+func (s *Server) Close() error {
+	err := s.DHT.Close()
+	if err != nil {
+		return err
+	}
+	return s.Host.Close()
+}
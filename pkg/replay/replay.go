@@ -0,0 +1,130 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/yqs112358/cross-clipboard/pkg/utils/stringutil"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const replayFileName = "replay.json"
+
+// WindowSize the number of sequence numbers below the highest one accepted out of order
+const WindowSize = 64
+
+var (
+	// ErrNoSequence the message doesn't have a sequence number
+	ErrNoSequence = errors.New("message has no sequence number")
+	// ErrDuplicate the sequence number is already received
+	ErrDuplicate = errors.New("duplicate sequence number")
+	// ErrOutOfWindow the sequence number is too old to be checked
+	ErrOutOfWindow = errors.New("sequence number out of the replay window")
+)
+
+// Window a sliding window of the sequence numbers received from one sender
+type Window struct {
+	highest uint64 // the highest sequence number received
+	bitmap  uint64 // bit i is set if highest - i is received
+}
+
+// Accept record the sequence number, returns an error if it's already received or older than the window
+func (w *Window) Accept(sequence uint64) error {
+	if sequence == 0 {
+		return ErrNoSequence
+	}
+
+	if sequence > w.highest {
+		shift := sequence - w.highest
+		if shift >= WindowSize {
+			w.bitmap = 0
+		} else {
+			w.bitmap <<= shift
+		}
+		w.bitmap |= 1
+		w.highest = sequence
+		return nil
+	}
+
+	offset := w.highest - sequence
+	if offset >= WindowSize {
+		return ErrOutOfWindow
+	}
+	if w.bitmap&(1<<offset) != 0 {
+		return ErrDuplicate
+	}
+	w.bitmap |= 1 << offset
+	return nil
+}
+
+// Filter the replay windows of the senders, the highest sequence number of each sender is kept in the replay file,
+// so the messages received before restarting are still rejected
+type Filter struct {
+	filePath string
+	windows  map[string]*Window
+
+	mu sync.Mutex
+}
+
+// NewFilter create a new replay filter with the highest sequence numbers loaded from the replay file in the config directory,
+// all sequence numbers up to the loaded one are rejected
+func NewFilter(configDirPath string) (*Filter, error) {
+	f := &Filter{
+		filePath: stringutil.JoinURL(configDirPath, replayFileName),
+		windows:  make(map[string]*Window),
+	}
+
+	b, err := os.ReadFile(f.filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return f, nil
+		}
+		return nil, xerror.NewRuntimeError("can not read replay file").Wrap(err)
+	}
+
+	highest := map[string]uint64{}
+	err = json.Unmarshal(b, &highest)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not unmarshal replay json").Wrap(err)
+	}
+	for senderID, sequence := range highest {
+		// the messages in the window before restarting are not known, all of them are taken as received
+		f.windows[senderID] = &Window{highest: sequence, bitmap: ^uint64(0)}
+	}
+	return f, nil
+}
+
+// Accept record the sequence number of the sender, returns an error if the message is replayed
+func (f *Filter) Accept(senderID string, sequence uint64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	w, ok := f.windows[senderID]
+	if !ok {
+		w = &Window{}
+		f.windows[senderID] = w
+	}
+	return w.Accept(sequence)
+}
+
+// Save write the highest sequence number of each sender to the replay file
+func (f *Filter) Save() error {
+	f.mu.Lock()
+	highest := make(map[string]uint64, len(f.windows))
+	for senderID, w := range f.windows {
+		highest[senderID] = w.highest
+	}
+	f.mu.Unlock()
+
+	b, err := json.MarshalIndent(highest, "", "  ")
+	if err != nil {
+		return xerror.NewRuntimeError("can not marshal replay sequence numbers").Wrap(err)
+	}
+	err = os.WriteFile(f.filePath, b, 0644)
+	if err != nil {
+		return xerror.NewRuntimeError("can not write replay file").Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWindowAccept(t *testing.T) {
+	tests := []struct {
+		name      string
+		received  []uint64
+		sequence  uint64
+		wantError error
+	}{
+		{name: "first message", sequence: 100},
+		{name: "next message", received: []uint64{100}, sequence: 101},
+		{name: "gap", received: []uint64{100}, sequence: 150},
+		{name: "no sequence", sequence: 0, wantError: ErrNoSequence},
+		{name: "duplicate highest", received: []uint64{100}, sequence: 100, wantError: ErrDuplicate},
+		{name: "duplicate in window", received: []uint64{90, 100}, sequence: 90, wantError: ErrDuplicate},
+		{name: "out of order in window", received: []uint64{90, 100}, sequence: 95},
+		{name: "oldest in window", received: []uint64{100}, sequence: 100 - WindowSize + 1},
+		{name: "out of window", received: []uint64{100}, sequence: 100 - WindowSize, wantError: ErrOutOfWindow},
+		{name: "duplicate after shift", received: []uint64{10, 20, 70}, sequence: 20, wantError: ErrDuplicate},
+		{name: "window reset by large jump", received: []uint64{10, 1000}, sequence: 999},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &Window{}
+			for _, sequence := range test.received {
+				if err := w.Accept(sequence); err != nil {
+					t.Fatalf("can not accept sequence %d: %v", sequence, err)
+				}
+			}
+			err := w.Accept(test.sequence)
+			if !errors.Is(err, test.wantError) {
+				t.Errorf("got error %v, wanted %v", err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestFilterSeparateSenders(t *testing.T) {
+	f, err := NewFilter(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Accept("a", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Accept("b", 1); err != nil {
+		t.Errorf("sequence of another sender is rejected: %v", err)
+	}
+	if err := f.Accept("a", 1); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("got error %v, wanted %v", err, ErrDuplicate)
+	}
+}
+
+func TestFilterLoad(t *testing.T) {
+	tests := []struct {
+		name      string
+		senderID  string
+		sequence  uint64
+		wantError error
+	}{
+		{name: "highest before restarting", senderID: "a", sequence: 100, wantError: ErrDuplicate},
+		{name: "in window before restarting", senderID: "a", sequence: 90, wantError: ErrDuplicate},
+		{name: "out of window before restarting", senderID: "a", sequence: 10, wantError: ErrOutOfWindow},
+		{name: "next message", senderID: "a", sequence: 101},
+		{name: "another sender", senderID: "b", sequence: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			f, err := NewFilter(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, sequence := range []uint64{90, 100} {
+				if err := f.Accept("a", sequence); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := f.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			loaded, err := NewFilter(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = loaded.Accept(test.senderID, test.sequence)
+			if !errors.Is(err, test.wantError) {
+				t.Errorf("got error %v, wanted %v", err, test.wantError)
+			}
+		})
+	}
+}
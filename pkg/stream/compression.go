@@ -0,0 +1,55 @@
+package stream
+
+import (
+	"github.com/yqs112358/cross-clipboard/pkg/compression"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+)
+
+// compressionCapabilities the capability of each algorithm in the preferred order
+var compressionCapabilities = []struct {
+	capability  Capability
+	compression protobuf.Compression
+}{
+	{CapabilityCompressionZstd, protobuf.Compression_COMPRESSION_ZSTD},
+	{CapabilityCompressionGzip, protobuf.Compression_COMPRESSION_GZIP},
+}
+
+// compressionFor returns the preferred compression supported by the device to send the data,
+// none if the compression is disabled, the data is small or the data is already compressed like png
+func (s *StreamHandler) compressionFor(dv *device.Device, data []byte) protobuf.Compression {
+	if !s.config.Compression.Enabled || len(data) < s.config.Compression.MinSize || compression.IsCompressed(data) {
+		return protobuf.Compression_COMPRESSION_NONE
+	}
+	for _, c := range compressionCapabilities {
+		if dv.HasCapability(c.capability) {
+			return c.compression
+		}
+	}
+	return protobuf.Compression_COMPRESSION_NONE
+}
+
+// compressionAlgorithm returns the algorithm of the protobuf compression
+func compressionAlgorithm(c protobuf.Compression) compression.Algorithm {
+	switch c {
+	case protobuf.Compression_COMPRESSION_GZIP:
+		return compression.Gzip
+	case protobuf.Compression_COMPRESSION_ZSTD:
+		return compression.Zstd
+	case protobuf.Compression_COMPRESSION_NONE:
+		return compression.None
+	default:
+		// unknown compression fails to decompress
+		return compression.Algorithm(c.String())
+	}
+}
+
+// compress compress the data by the compression at the configured level
+func (s *StreamHandler) compress(c protobuf.Compression, data []byte) ([]byte, error) {
+	return compression.Compress(compressionAlgorithm(c), s.config.Compression.Level, data)
+}
+
+// decompress decompress the data by the compression, the data larger than max size is refused
+func decompress(c protobuf.Compression, data []byte, maxSize int) ([]byte, error) {
+	return compression.Decompress(compressionAlgorithm(c), data, maxSize)
+}
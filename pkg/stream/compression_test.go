@@ -0,0 +1,210 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+)
+
+// newCompressionTestHandler returns a stream handler and a device sending to the handler itself
+func newCompressionTestHandler(tb testing.TB, capabilities ...Capability) (*StreamHandler, *device.Device) {
+	armored, err := crypto.GeneratePGPKey("test")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	privKey, err := crypto.UnmarshalPGPKey(armored, nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pubKey, err := privKey.GetPublicKey()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	pgpPubKey, err := crypto.ByteToPGPKey(pubKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	encrypter, err := crypto.NewPGPEncrypter(pgpPubKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	decrypter, err := crypto.NewPGPDecrypter(privKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	s := &StreamHandler{
+		config: &config.Config{
+			MaxSize: 5 << 20,
+			Compression: config.CompressionConfig{
+				Enabled: true,
+				Level:   3,
+				MinSize: 512,
+			},
+		},
+		pgpDecrypter: decrypter,
+	}
+	dv := &device.Device{
+		PgpEncrypter: encrypter,
+		Capabilities: capabilities,
+	}
+	return s, dv
+}
+
+// screenshot returns raw rgba pixels of a window like picture with flat areas, lines and some noise
+func screenshot(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	r := rand.New(rand.NewSource(1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+			switch {
+			case y < 32: // title bar
+				c = color.RGBA{R: 45, G: 45, B: 48, A: 255}
+			case x < width/5: // side bar
+				c = color.RGBA{R: 30, G: 30, B: 30, A: 255}
+			case y%20 < 12 && x%(7+y%5) < 5: // text lines
+				c = color.RGBA{R: uint8(r.Intn(64)), G: uint8(r.Intn(64)), B: uint8(r.Intn(64)), A: 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestCompressionFor(t *testing.T) {
+	text := bytes.Repeat([]byte("a"), 1024)
+	pngBytes := append([]byte("\x89PNG\r\n\x1a\n"), text...)
+	tests := []struct {
+		name         string
+		disabled     bool
+		capabilities []Capability
+		data         []byte
+		want         protobuf.Compression
+	}{
+		{name: "zstd preferred", capabilities: []Capability{CapabilityCompressionGzip, CapabilityCompressionZstd}, data: text, want: protobuf.Compression_COMPRESSION_ZSTD},
+		{name: "gzip only", capabilities: []Capability{CapabilityCompressionGzip}, data: text, want: protobuf.Compression_COMPRESSION_GZIP},
+		{name: "not supported", capabilities: []Capability{CapabilityHeartbeat}, data: text, want: protobuf.Compression_COMPRESSION_NONE},
+		{name: "disabled", disabled: true, capabilities: []Capability{CapabilityCompressionZstd}, data: text, want: protobuf.Compression_COMPRESSION_NONE},
+		{name: "smaller than min size", capabilities: []Capability{CapabilityCompressionZstd}, data: text[:100], want: protobuf.Compression_COMPRESSION_NONE},
+		{name: "png", capabilities: []Capability{CapabilityCompressionZstd}, data: pngBytes, want: protobuf.Compression_COMPRESSION_NONE},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &StreamHandler{config: &config.Config{Compression: config.CompressionConfig{Enabled: !tt.disabled, MinSize: 512}}}
+			dv := &device.Device{Capabilities: tt.capabilities}
+			if got := s.compressionFor(dv, tt.data); got != tt.want {
+				t.Errorf("compressionFor() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClipboardDataCompressionRoundTrip(t *testing.T) {
+	s, dv := newCompressionTestHandler(t)
+	clipboardData := &protobuf.ClipboardData{
+		Data:     bytes.Repeat([]byte("hello cross clipboard\n"), 100),
+		DataSize: 2200,
+	}
+	compressions := []protobuf.Compression{
+		protobuf.Compression_COMPRESSION_NONE,
+		protobuf.Compression_COMPRESSION_GZIP,
+		protobuf.Compression_COMPRESSION_ZSTD,
+	}
+	for _, compression := range compressions {
+		t.Run(compression.String(), func(t *testing.T) {
+			encrypted, err := s.encryptClipboardData(dv, compression, clipboardData)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := s.decryptClipboardData(compression, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(decrypted.Data, clipboardData.Data) {
+				t.Error("decrypted data is not the same as the clipboard data")
+			}
+		})
+	}
+}
+
+// BenchmarkClipboardWireSize reports the bytes on wire of the clipboard frame by each compression
+func BenchmarkClipboardWireSize(b *testing.B) {
+	var text bytes.Buffer
+	for i := 0; text.Len() < 64<<10; i++ {
+		text.WriteString("func (s *StreamHandler) sendClipboard(clipboardBytes []byte, isImage bool) // line ")
+		text.WriteString(string(rune('0' + i%10)))
+		text.WriteString("\n")
+	}
+
+	img := screenshot(640, 400)
+	var pngScreenshot bytes.Buffer
+	err := png.Encode(&pngScreenshot, img)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// raw screenshot as a tiny header of the size followed by the rgba pixels
+	rawScreenshot := binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, 640), 400)
+	rawScreenshot = append(rawScreenshot, img.Pix...)
+
+	payloads := []struct {
+		name    string
+		data    []byte
+		isImage bool
+	}{
+		{name: "text", data: text.Bytes()},
+		{name: "screenshot_png", data: pngScreenshot.Bytes(), isImage: true},
+		{name: "screenshot_raw", data: rawScreenshot, isImage: true},
+	}
+	compressions := []struct {
+		name       string
+		capability Capability
+	}{
+		{name: "none"},
+		{name: "gzip", capability: CapabilityCompressionGzip},
+		{name: "zstd", capability: CapabilityCompressionZstd},
+	}
+
+	for _, payload := range payloads {
+		clipboardData := &protobuf.ClipboardData{
+			IsImage:  payload.isImage,
+			Data:     payload.data,
+			DataSize: uint32(len(payload.data)),
+		}
+		for _, c := range compressions {
+			b.Run(payload.name+"/"+c.name, func(b *testing.B) {
+				s, dv := newCompressionTestHandler(b, c.capability)
+				s.config.MaxSize = 16 << 20
+				var wireBytes int
+				b.SetBytes(int64(len(payload.data)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					compression := s.compressionFor(dv, payload.data)
+					encrypted, err := s.encryptClipboardData(dv, compression, clipboardData)
+					if err != nil {
+						b.Fatal(err)
+					}
+					envelope := newEnvelope()
+					envelope.Compression = compression
+					envelope.Payload = &protobuf.Envelope_Clipboard{Clipboard: encrypted}
+					frame, err := s.encodeEnvelope(envelope)
+					if err != nil {
+						b.Fatal(err)
+					}
+					wireBytes = len(frame)
+				}
+				b.ReportMetric(float64(wireBytes), "wire-bytes")
+				b.ReportMetric(float64(wireBytes)/float64(len(payload.data)), "wire/raw")
+			})
+		}
+	}
+}
@@ -1,20 +1,23 @@
 package stream
 
-import "github.com/libp2p/go-libp2p/core/protocol"
-
-type (
-	DataType byte
-	Signal   byte
+import (
+	"github.com/libp2p/go-libp2p/core/protocol"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
 )
 
-const (
-	PROTOCAL_ID protocol.ID = protocol.ID("/cross-clipboard/0.0.1")
+type Signal = protobuf.SignalType
 
-	// data type is the first byte after data size to identify the message type
-	DataTypeDevice    DataType = 0xFF // use for device data
-	DataTypeClipboard DataType = 0xFE // use for clipboard data
+const (
+	// CONTROL_PROTOCAL_ID the protocol of the handshakes, signals and receipts
+	CONTROL_PROTOCAL_ID protocol.ID = protocol.ID("/cross-clipboard/control/2.0.0")
+	// DATA_PROTOCAL_ID the protocol of the clipboard payloads, opened by the dialing device after the control stream
+	DATA_PROTOCAL_ID protocol.ID = protocol.ID("/cross-clipboard/data/2.0.0")
+	// LEGACY_PROTOCAL_ID the protocol before the versioned frame, only handled to report the incompatible peer
+	LEGACY_PROTOCAL_ID protocol.ID = protocol.ID("/cross-clipboard/0.0.1")
 
-	// signal is the first byte after data size to identify the signal type
-	SignalDisconnect        Signal = 0xFD // ending exit signal
-	SignalRequestDeviceData Signal = 0xFC // request device data signal
+	SignalDisconnect        = protobuf.SignalType_SIGNAL_DISCONNECT          // ending exit signal
+	SignalRequestDeviceData = protobuf.SignalType_SIGNAL_REQUEST_DEVICE_DATA // request device data signal
+	SignalPairingConfirmed  = protobuf.SignalType_SIGNAL_PAIRING_CONFIRMED   // the user confirmed the pairing, or the device is already trusted
+	SignalPing              = protobuf.SignalType_SIGNAL_PING                // heartbeat to check the device is alive
+	SignalPong              = protobuf.SignalType_SIGNAL_PONG                // reply of the heartbeat
 )
@@ -6,47 +6,39 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// decodeData decode message data to protobuf type `| data size (int 4 bytes) | data type (enum 1 byte) | protobuf message (struct n bytes) |`
-func (s *StreamHandler) decodeData(bytes []byte) (*protobuf.ClipboardData, *protobuf.DeviceData, *Signal, error) {
-	length := len(bytes)
-	if length <= 0 {
-		return nil, nil, nil, xerror.NewRuntimeErrorf("data length <= 0: %d", length)
+// decodeEnvelope decode the frame payload to an envelope
+func (s *StreamHandler) decodeEnvelope(header frameHeader, bytes []byte) (*protobuf.Envelope, error) {
+	if header.Type != FrameTypeEnvelope {
+		return nil, xerror.NewRuntimeErrorf("unknown frame type %#02x", byte(header.Type))
 	}
 
-	// get data type from the last byte before EOF
-	dataType := bytes[0]
+	envelope := &protobuf.Envelope{}
+	err := proto.Unmarshal(bytes, envelope)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error unmarshaling envelope").Wrap(err)
+	}
+	return envelope, nil
+}
 
-	// remove data type bytes
-	bytes = bytes[1:]
+// clipboardDataOverhead the size of the clipboard data fields other than the data
+const clipboardDataOverhead = 1 << 10
 
-	switch dataType {
-	case byte(DataTypeClipboard):
-		// decrypt clipboard data
-		decrypedData, err := s.pgpDecrypter.DecryptMessage(bytes)
-		if err != nil {
-			return nil, nil, nil, xerror.NewRuntimeError("error to decrypt clipboard data").Wrap(err)
-		}
+// decryptClipboardData decrypt the clipboard data encrypted by this device public key and decompress it
+func (s *StreamHandler) decryptClipboardData(compression protobuf.Compression, bytes []byte) (*protobuf.ClipboardData, error) {
+	decrypedData, err := s.pgpDecrypter.DecryptMessage(bytes)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error to decrypt clipboard data").Wrap(err)
+	}
+
+	decrypedData, err = decompress(compression, decrypedData, s.maxClipboardSize()+clipboardDataOverhead)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error to decompress clipboard data").Wrap(err)
+	}
 
-		clipboardData := &protobuf.ClipboardData{}
-		err = proto.Unmarshal(decrypedData, clipboardData)
-		if err != nil {
-			return nil, nil, nil, xerror.NewRuntimeError("error unmarshaling clipboard data").Wrap(err)
-		}
-		return clipboardData, nil, nil, nil
-	case byte(DataTypeDevice):
-		deviceData := &protobuf.DeviceData{}
-		err := proto.Unmarshal(bytes, deviceData)
-		if err != nil {
-			return nil, nil, nil, xerror.NewRuntimeError("error unmarshaling device data").Wrap(err)
-		}
-		return nil, deviceData, nil, nil
-	case byte(SignalDisconnect):
-		sn := SignalDisconnect
-		return nil, nil, &sn, nil
-	case byte(SignalRequestDeviceData):
-		sn := SignalRequestDeviceData
-		return nil, nil, &sn, nil
-	default:
-		return nil, nil, nil, xerror.NewRuntimeError("unknown data type")
+	clipboardData := &protobuf.ClipboardData{}
+	err = proto.Unmarshal(decrypedData, clipboardData)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error unmarshaling clipboard data").Wrap(err)
 	}
+	return clipboardData, nil
 }
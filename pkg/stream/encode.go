@@ -1,68 +1,64 @@
 package stream
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 	"google.golang.org/protobuf/proto"
 )
 
-// encodeClipboardData encode data for stream package `| data size (int 4 bytes) | data type (enum 1 byte) | protobuf message (struct n bytes) |`
-func (s *StreamHandler) encodeClipboardData(dv *device.Device, clipboardData *protobuf.ClipboardData) ([]byte, error) {
-	packageData := []byte{}
+// newEnvelope create an envelope with a new message id and the current time
+func newEnvelope() *protobuf.Envelope {
+	return &protobuf.Envelope{
+		MessageId: newMessageID(),
+		Timestamp: time.Now().UnixMilli(),
+	}
+}
 
-	// create proto clipboard data
-	clipboardDataBytes, err := proto.Marshal(clipboardData)
+// newMessageID returns a random message id
+func newMessageID() string {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
 	if err != nil {
-		return nil, xerror.NewRuntimeError("error marshaling clipboard data").Wrap(err)
+		// crypto/rand never fails on supported platforms
+		panic(err)
 	}
+	return hex.EncodeToString(id)
+}
 
-	// encrypt clipboard data
-	clipboardDataEncrypted, err := dv.PgpEncrypter.EncryptMessage(clipboardDataBytes)
+// encodeEnvelope encode the envelope to a frame
+func (s *StreamHandler) encodeEnvelope(envelope *protobuf.Envelope) ([]byte, error) {
+	envelopeBytes, err := proto.Marshal(envelope)
 	if err != nil {
-		return nil, xerror.NewRuntimeError("error to encrypt clipboard data").Wrap(err)
+		return nil, xerror.NewRuntimeError("error marshaling envelope").Wrap(err)
 	}
-	encryptedDataSize := len(clipboardDataEncrypted)
 
-	// append data size + 1 bytes for data type
-	packageData = append(packageData, intToBytes(encryptedDataSize+1)...)
-	// append data type
-	packageData = append(packageData, byte(DataTypeClipboard))
-	// append message
-	packageData = append(packageData, clipboardDataEncrypted...)
-
-	return packageData, nil
+	return encodeFrame(FrameTypeEnvelope, 0, envelopeBytes)
 }
 
-// encodeDeviceData encode data for stream package `| data size (int 4 bytes) | data type (enum 1 byte) | protobuf message (struct n bytes) |`
-func (s *StreamHandler) encodeDeviceData(data *protobuf.DeviceData) ([]byte, error) {
-	packageData := []byte{}
-
-	// create proto device data
-	deviceDataBytes, err := proto.Marshal(data)
+// encryptClipboardData encode clipboard data compressed by the compression and encrypted by the device public key
+func (s *StreamHandler) encryptClipboardData(dv *device.Device, compression protobuf.Compression, clipboardData *protobuf.ClipboardData) ([]byte, error) {
+	// create proto clipboard data
+	clipboardDataBytes, err := proto.Marshal(clipboardData)
 	if err != nil {
-		return nil, xerror.NewRuntimeError("error marshaling device data").Wrap(err)
+		return nil, xerror.NewRuntimeError("error marshaling clipboard data").Wrap(err)
 	}
-	dataSize := len(deviceDataBytes)
-
-	// append data size + 1 bytes for data type
-	packageData = append(packageData, intToBytes(dataSize+1)...)
-	// append DATA TYPE
-	packageData = append(packageData, byte(DataTypeDevice))
-	// append message
-	packageData = append(packageData, deviceDataBytes...)
 
-	return packageData, nil
-}
-
-// encodeSignal encode signal type `| data size (int 4 bytes) | signal type |`
-func (s *StreamHandler) encodeSignal(signal Signal) ([]byte, error) {
-	packageData := []byte{}
+	// compress before encrypting, the encrypted data can not be compressed
+	clipboardDataBytes, err = s.compress(compression, clipboardDataBytes)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error to compress clipboard data").Wrap(err)
+	}
 
-	// append data size + 1 bytes for data type
-	packageData = append(packageData, intToBytes(1)...)
-	// append DATA TYPE
-	packageData = append(packageData, byte(signal))
+	// encrypt clipboard data
+	clipboardDataEncrypted, err := dv.PgpEncrypter.EncryptMessage(clipboardDataBytes)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("error to encrypt clipboard data").Wrap(err)
+	}
 
-	return packageData, nil
+	return clipboardDataEncrypted, nil
 }
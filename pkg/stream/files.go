@@ -0,0 +1,144 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/files"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// filesArchive the archive of the copied files in a temp file, it's shared by the transfers to all devices
+// and removed when the last transfer is sent or dropped
+type filesArchive struct {
+	file *os.File
+	size int64
+	time time.Time
+
+	refs atomic.Int32
+}
+
+// acquire add a reference of a transfer to the archive
+func (a *filesArchive) acquire() {
+	a.refs.Add(1)
+}
+
+// release remove a reference of the archive, the temp file is removed by the last reference
+func (a *filesArchive) release() {
+	if a.refs.Add(-1) == 0 {
+		a.file.Close()
+		os.Remove(a.file.Name())
+	}
+}
+
+// reader returns a reader of the archive from the beginning, the archive can be read by many transfers at the same time
+func (a *filesArchive) reader() *io.SectionReader {
+	return io.NewSectionReader(a.file, 0, a.size)
+}
+
+// packFiles pack the local files copied to the clipboard into an archive in a temp file,
+// the local paths are useless on the other devices, the returned archive is referenced by the caller
+func (s *StreamHandler) packFiles(paths []string, t time.Time) (*filesArchive, error) {
+	file, err := os.CreateTemp("", "cross-clipboard-files-*")
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not create files archive temp file").Wrap(err)
+	}
+	archive := &filesArchive{file: file, time: t}
+	archive.acquire()
+
+	archive.size, err = files.Pack(file, paths, s.config.Files.MaxSize)
+	if errors.Is(err, files.ErrTooLarge) {
+		archive.release()
+		return nil, xerror.NewRuntimeErrorf("copied files > config files max size %d", s.config.Files.MaxSize)
+	}
+	if err != nil {
+		archive.release()
+		return nil, err
+	}
+	s.logChan <- fmt.Sprintf("packed %d copied files, size: %d", len(paths), archive.size)
+	return archive, nil
+}
+
+// copiedFiles returns the paths of the local files copied to the clipboard to be packed,
+// a text which looks like a file uri is only a text, the files are packed only from the uri list format
+func (s *StreamHandler) copiedFiles(cb *clipboard.Clipboard) ([]string, bool) {
+	if !s.config.Files.Enabled || cb.Selection != clipboard.SelectionClipboard {
+		return nil, false
+	}
+
+	uriList, ok := cb.Format(clipboard.MimeURIList)
+	if !ok {
+		return nil, false
+	}
+	return files.ParseURIList(uriList)
+}
+
+// maxClipboardSize returns the max size of the clipboard from the devices, the files may be larger than the clipboard
+func (s *StreamHandler) maxClipboardSize() int {
+	if s.config.Files.Enabled && s.config.Files.MaxSize > s.config.MaxSize {
+		return s.config.Files.MaxSize
+	}
+	return s.config.MaxSize
+}
+
+// applyClipboard write the clipboard received from the device, the files are extracted to the download directory
+// and the clipboard is replaced by the uri list of the extracted files, returns the status of the receipt
+func (s *StreamHandler) applyClipboard(dv *device.Device, cb clipboard.Clipboard) protobuf.AckStatus {
+	if !s.clipboardManager.SyncsSelection(cb.Selection) {
+		s.logChan <- fmt.Sprintf("ignored %s selection from %s, it's not synced", cb.Selection, dv.AddressInfo.ID.Loggable())
+		return protobuf.AckStatus_ACK_STATUS_FILTERED
+	}
+
+	archive, ok := cb.Format(clipboard.MimeFiles)
+	if !ok {
+		if cb.TotalSize() > s.config.MaxSize {
+			s.errorChan <- xerror.NewRuntimeErrorf("clipboard size %d > config max size %d", cb.TotalSize(), s.config.MaxSize)
+			return protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE
+		}
+		return s.writeClipboard(dv, cb)
+	}
+
+	return s.applyFiles(dv, bytes.NewReader(archive), cb.Time)
+}
+
+// applyFiles extract the archive of the files received from the device to the download directory
+// and write the uri list of the extracted files, returns the status of the receipt
+func (s *StreamHandler) applyFiles(dv *device.Device, archive io.Reader, t time.Time) protobuf.AckStatus {
+	if !s.config.Files.IsAllowed(dv.AddressInfo.ID.String()) {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored files from %s, the device is not allowed to send files", dv.AddressInfo.ID.Loggable())
+		return protobuf.AckStatus_ACK_STATUS_FILTERED
+	}
+
+	paths, err := files.Unpack(archive, s.config.Files.DownloadDir, s.config.Files.MaxSize)
+	if errors.Is(err, files.ErrTooLarge) {
+		s.errorChan <- xerror.NewRuntimeErrorf("files from %s > config files max size %d", dv.AddressInfo.ID.Loggable(), s.config.Files.MaxSize)
+		return protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE
+	}
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("can not extract files from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		return protobuf.AckStatus_ACK_STATUS_FAILED
+	}
+	s.logChan <- fmt.Sprintf("received %d files to %s, peer: %s", len(paths), s.config.Files.DownloadDir, dv.AddressInfo.ID.Loggable())
+
+	received := clipboard.NewClipboard([]clipboard.Format{{MimeType: clipboard.MimeURIList, Data: files.URIList(paths)}}, t)
+	received.Device = dv
+	return s.writeClipboard(dv, *received)
+}
+
+// writeClipboard write the clipboard received from the device to the clipboard backend
+func (s *StreamHandler) writeClipboard(dv *device.Device, cb clipboard.Clipboard) protobuf.AckStatus {
+	err := s.clipboardManager.WriteClipboard(cb)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("can not write clipboard from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		return protobuf.AckStatus_ACK_STATUS_FAILED
+	}
+	return protobuf.AckStatus_ACK_STATUS_APPLIED
+}
@@ -0,0 +1,278 @@
+package stream
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/devicemanager"
+	"github.com/yqs112358/cross-clipboard/pkg/files"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+)
+
+func TestApplyClipboard(t *testing.T) {
+	srcDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("file a"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copiedFiles := files.URIList([]string{filepath.Join(srcDir, "a.txt")})
+
+	tests := []struct {
+		name           string
+		filesEnabled   bool
+		allowedDevices []string
+		primaryMode    string
+		selection      clipboard.Selection
+		copied         []clipboard.Format
+		wantStatus     protobuf.AckStatus
+		want           func(t *testing.T, backend *clipboard.MemoryBackend, downloadDir string)
+	}{
+		{
+			name:       "text",
+			copied:     []clipboard.Format{{MimeType: clipboard.MimeTextPlain, Data: []byte("hello")}},
+			wantStatus: protobuf.AckStatus_ACK_STATUS_APPLIED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, _ string) {
+				got, _ := backend.Read(clipboard.MimeTextPlain)
+				if string(got) != "hello" {
+					t.Errorf("got %q, wanted %q", got, "hello")
+				}
+			},
+		},
+		{
+			name:       "too large",
+			copied:     []clipboard.Format{{MimeType: clipboard.MimeTextPlain, Data: make([]byte, 65)}},
+			wantStatus: protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, _ string) {
+				if got, _ := backend.Read(clipboard.MimeTextPlain); got != nil {
+					t.Errorf("got %q, wanted the clipboard unchanged", got)
+				}
+			},
+		},
+		{
+			name:        "primary",
+			primaryMode: clipboard.PrimarySeparate,
+			selection:   clipboard.SelectionPrimary,
+			copied:      []clipboard.Format{{MimeType: clipboard.MimeTextPlain, Data: []byte("selected")}},
+			wantStatus:  protobuf.AckStatus_ACK_STATUS_APPLIED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, _ string) {
+				primary, _ := backend.Primary()
+				got, _ := primary.Read(clipboard.MimeTextPlain)
+				if string(got) != "selected" {
+					t.Errorf("got primary %q, wanted %q", got, "selected")
+				}
+				if got, _ := backend.Read(clipboard.MimeTextPlain); got != nil {
+					t.Errorf("got clipboard %q, wanted the clipboard unchanged", got)
+				}
+			},
+		},
+		{
+			name:        "primary not synced",
+			primaryMode: clipboard.PrimaryOff,
+			selection:   clipboard.SelectionPrimary,
+			copied:      []clipboard.Format{{MimeType: clipboard.MimeTextPlain, Data: []byte("selected")}},
+			wantStatus:  protobuf.AckStatus_ACK_STATUS_FILTERED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, _ string) {
+				if got, _ := backend.Read(clipboard.MimeTextPlain); got != nil {
+					t.Errorf("got clipboard %q, wanted the clipboard unchanged", got)
+				}
+			},
+		},
+		{
+			name:         "files",
+			filesEnabled: true,
+			copied:       []clipboard.Format{{MimeType: clipboard.MimeURIList, Data: copiedFiles}},
+			wantStatus:   protobuf.AckStatus_ACK_STATUS_APPLIED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, downloadDir string) {
+				// the uri list is written as the text, the backend is written with one representation
+				got, _ := backend.Read(clipboard.MimeTextPlain)
+				paths, ok := files.ParseURIList(got)
+				if !ok || len(paths) != 1 || !strings.HasPrefix(paths[0], downloadDir) {
+					t.Fatalf("got uri list %q, wanted the file in %s", got, downloadDir)
+				}
+				data, err := os.ReadFile(paths[0])
+				if err != nil || string(data) != "file a" {
+					t.Errorf("got file %q %v, wanted %q", data, err, "file a")
+				}
+			},
+		},
+		{
+			name:         "file uri as text",
+			filesEnabled: true,
+			copied:       []clipboard.Format{{MimeType: clipboard.MimeTextPlain, Data: copiedFiles}},
+			wantStatus:   protobuf.AckStatus_ACK_STATUS_APPLIED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, downloadDir string) {
+				got, _ := backend.Read(clipboard.MimeTextPlain)
+				if string(got) != string(copiedFiles) {
+					t.Errorf("got %q, wanted the text %q", got, copiedFiles)
+				}
+				entries, _ := os.ReadDir(downloadDir)
+				if len(entries) != 0 {
+					t.Errorf("got %d files in %s, wanted the file not sent", len(entries), downloadDir)
+				}
+			},
+		},
+		{
+			name:           "files from a device allowed by name",
+			filesEnabled:   true,
+			allowedDevices: []string{"laptop"},
+			copied:         []clipboard.Format{{MimeType: clipboard.MimeURIList, Data: copiedFiles}},
+			wantStatus:     protobuf.AckStatus_ACK_STATUS_FILTERED,
+			want: func(t *testing.T, backend *clipboard.MemoryBackend, downloadDir string) {
+				entries, _ := os.ReadDir(downloadDir)
+				if len(entries) != 0 {
+					t.Errorf("got %d files in %s, wanted the files refused", len(entries), downloadDir)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, dv := newCompressionTestHandler(t)
+			dv.AddressInfo = peer.AddrInfo{ID: "peer-a"}
+			dv.Name = "laptop"
+			s.config.MaxSize = 64
+			s.config.MaxHistory = 10
+			s.config.Files = config.FilesConfig{
+				Enabled:        test.filesEnabled,
+				DownloadDir:    t.TempDir(),
+				MaxSize:        1 << 20,
+				AllowedDevices: test.allowedDevices,
+			}
+			s.logChan = make(chan string, 10)
+			s.errorChan = make(chan error, 10)
+			s.config.Clipboard.Primary = test.primaryMode
+			backend := clipboard.NewMemoryBackend()
+			var primary clipboard.ClipboardBackend
+			if test.primaryMode != "" && test.primaryMode != clipboard.PrimaryOff {
+				primary, _ = backend.Primary()
+			}
+			s.clipboardManager = clipboard.NewClipboardManager(s.config, backend, primary)
+
+			// the clipboard copied on the sender is packed and sent through the clipboard message
+			copied := clipboard.NewClipboard(test.copied, time.Now())
+			copied.Selection = test.selection
+			cb := copied
+			if paths, ok := s.copiedFiles(copied); ok {
+				cb = packTestFiles(t, s, paths, copied.Time)
+			}
+			encrypted, err := s.encryptClipboardData(dv, protobuf.Compression_COMPRESSION_NONE, cb.ToProtobuf())
+			if err != nil {
+				t.Fatal(err)
+			}
+			cd, err := s.decryptClipboardData(protobuf.Compression_COMPRESSION_NONE, encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			status := s.applyClipboard(dv, clipboard.FromProtobuf(cd, dv))
+			if status != test.wantStatus {
+				t.Errorf("got status %s, wanted %s", status, test.wantStatus)
+			}
+			test.want(t, backend, s.config.Files.DownloadDir)
+		})
+	}
+}
+
+// packTestFiles returns the clipboard of the archive of the files sent in one clipboard message
+func packTestFiles(t *testing.T, s *StreamHandler, paths []string, copiedAt time.Time) *clipboard.Clipboard {
+	archive, err := s.packFiles(paths, copiedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer archive.release()
+	data, err := io.ReadAll(archive.reader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &clipboard.Clipboard{Time: copiedAt, Formats: []clipboard.Format{{MimeType: clipboard.MimeFiles, Data: data}}}
+}
+
+func TestSendClipboardFiles(t *testing.T) {
+	srcDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(srcDir, "a.txt"), []byte("file a"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copiedFiles := files.URIList([]string{filepath.Join(srcDir, "a.txt")})
+
+	tests := []struct {
+		name                string
+		changedWhilePacking bool
+		wantSent            bool
+	}{
+		{
+			name:     "files are sent as the archive",
+			wantSent: true,
+		},
+		{
+			name:                "clipboard changed while packing",
+			changedWhilePacking: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, dv := newCompressionTestHandler(t, CapabilityFiles, CapabilityChunkedTransfer)
+			dv.AddressInfo = peer.AddrInfo{ID: "peer-a"}
+			dv.Status = device.StatusConnected
+			s.config.ConfigDirPath = t.TempDir()
+			s.config.MaxHistory = 10
+			s.config.Files = config.FilesConfig{Enabled: true, MaxSize: 1 << 20}
+			s.logChan = make(chan string, 10)
+			s.errorChan = make(chan error, 10)
+			s.clipboardManager = clipboard.NewClipboardManager(s.config, clipboard.NewMemoryBackend(), nil)
+			s.deviceManager = devicemanager.NewDeviceManager(s.config)
+			s.deviceManager.AddDevice(dv)
+			ob := newOutbox(4, nil)
+			s.outboxes = map[peer.ID]*outbox{dv.AddressInfo.ID: ob}
+
+			copied := clipboard.NewClipboard([]clipboard.Format{{MimeType: clipboard.MimeURIList, Data: copiedFiles}}, time.Now())
+			if test.changedWhilePacking {
+				s.sendGeneration = 2
+				s.sendFiles(copied, []string{filepath.Join(srcDir, "a.txt")}, 1, false)
+			} else {
+				s.sendClipboard(copied)
+				history := s.clipboardManager.ClipboardsHistory
+				if len(history) != 1 {
+					t.Fatalf("got %d clipboards in the history, wanted the uri list", len(history))
+				}
+				if _, ok := history[0].Format(clipboard.MimeURIList); !ok {
+					t.Fatal("wanted the uri list in the history")
+				}
+			}
+
+			items := make(chan *outboxItem, 1)
+			go func() {
+				item, _ := ob.pop()
+				items <- item
+			}()
+			select {
+			case item := <-items:
+				if !test.wantSent {
+					t.Fatal("got the files sent, wanted them dropped")
+				}
+				if item.archive == nil {
+					t.Fatal("got the uri list sent, wanted the archive")
+				}
+				item.archive.release()
+				if _, err := os.Stat(item.archive.file.Name()); !os.IsNotExist(err) {
+					t.Error("the archive is not removed after it's sent")
+				}
+			case <-time.After(time.Second):
+				if test.wantSent {
+					t.Fatal("timeout waiting for the files sent")
+				}
+			}
+			ob.stop()
+		})
+	}
+}
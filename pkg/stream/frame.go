@@ -0,0 +1,98 @@
+package stream
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// frame format version 2, all integers are big endian
+//
+//	| magic (2 bytes "CC") | version (1 byte) | frame type (1 byte) | flags (uint16 2 bytes) | payload length (uint32 4 bytes) | payload (n bytes) |
+//
+// the payload is a protobuf Envelope, the first envelope on a stream is a hello from each side
+const (
+	FrameVersion byte = 2
+
+	frameHeaderLength = 10
+)
+
+var frameMagic = [2]byte{'C', 'C'}
+
+// FrameType type of the frame payload
+type FrameType byte
+
+const (
+	FrameTypeEnvelope FrameType = 0x01 // protobuf envelope
+)
+
+func (t FrameType) String() string {
+	switch t {
+	case FrameTypeEnvelope:
+		return "envelope"
+	default:
+		return "unknown"
+	}
+}
+
+// FrameFlags bit flags of the frame, a frame with flags unknown to the receiver is rejected
+// because the flags may change the meaning of the payload
+type FrameFlags uint16
+
+// knownFrameFlags all flags supported by this version
+const knownFrameFlags FrameFlags = 0
+
+// frameHeader header of a frame
+type frameHeader struct {
+	Version byte
+	Type    FrameType
+	Flags   FrameFlags
+	Length  int
+}
+
+// encodeFrame encode the payload to a frame
+func encodeFrame(frameType FrameType, flags FrameFlags, payload []byte) ([]byte, error) {
+	if len(payload) > math.MaxUint32 {
+		return nil, xerror.NewRuntimeErrorf("frame payload size %d > max size %d", len(payload), uint32(math.MaxUint32))
+	}
+
+	frame := make([]byte, frameHeaderLength, frameHeaderLength+len(payload))
+	frame[0] = frameMagic[0]
+	frame[1] = frameMagic[1]
+	frame[2] = FrameVersion
+	frame[3] = byte(frameType)
+	binary.BigEndian.PutUint16(frame[4:6], uint16(flags))
+	binary.BigEndian.PutUint32(frame[6:10], uint32(len(payload)))
+
+	return append(frame, payload...), nil
+}
+
+// readFrameHeader read and validate a frame header, the payload is left in the reader
+func readFrameHeader(r io.Reader) (frameHeader, error) {
+	bytes := make([]byte, frameHeaderLength)
+	_, err := io.ReadFull(r, bytes)
+	if err != nil {
+		return frameHeader{}, err
+	}
+
+	if bytes[0] != frameMagic[0] || bytes[1] != frameMagic[1] {
+		return frameHeader{}, xerror.NewRuntimeErrorf("invalid frame magic %x, the peer may use an incompatible version", bytes[0:2])
+	}
+
+	header := frameHeader{
+		Version: bytes[2],
+		Type:    FrameType(bytes[3]),
+		Flags:   FrameFlags(binary.BigEndian.Uint16(bytes[4:6])),
+		Length:  int(binary.BigEndian.Uint32(bytes[6:10])),
+	}
+	if header.Version != FrameVersion {
+		return frameHeader{}, xerror.NewRuntimeErrorf("unsupported frame version %d, supported version %d", header.Version, FrameVersion)
+	}
+	if unknown := header.Flags &^ knownFrameFlags; unknown != 0 {
+		return frameHeader{}, xerror.NewRuntimeErrorf("unsupported frame flags %#04x", uint16(unknown))
+	}
+
+	return header, nil
+}
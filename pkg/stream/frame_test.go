@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFrameRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		frameType FrameType
+		payload   []byte
+	}{
+		{name: "small payload", frameType: FrameTypeEnvelope, payload: []byte{0x0A, 0x01, 0x61}},
+		{name: "empty payload", frameType: FrameTypeEnvelope, payload: []byte{}},
+		{name: "large payload", frameType: FrameTypeEnvelope, payload: bytes.Repeat([]byte{0xAB}, 70000)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			frame, err := encodeFrame(test.frameType, 0, test.payload)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := bytes.NewReader(frame)
+			header, err := readFrameHeader(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if header.Version != FrameVersion || header.Type != test.frameType || header.Flags != 0 || header.Length != len(test.payload) {
+				t.Errorf("got header %+v, wanted type %s length %d", header, test.frameType, len(test.payload))
+			}
+
+			payload := make([]byte, header.Length)
+			if _, err := r.Read(payload); err != nil && header.Length > 0 {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(payload, test.payload) {
+				t.Errorf("got payload of %d bytes, wanted %d bytes", len(payload), len(test.payload))
+			}
+		})
+	}
+}
+
+func TestFrameHeaderIsBigEndian(t *testing.T) {
+	frame, err := encodeFrame(FrameTypeEnvelope, 0, make([]byte, 0x010203))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{'C', 'C', FrameVersion, byte(FrameTypeEnvelope), 0x00, 0x00, 0x00, 0x01, 0x02, 0x03}
+	if !bytes.Equal(frame[:frameHeaderLength], want) {
+		t.Errorf("got header %x, wanted %x", frame[:frameHeaderLength], want)
+	}
+}
+
+func TestReadFrameHeaderRejectsInvalidFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []byte
+	}{
+		{
+			name:   "legacy frame",
+			header: []byte{0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xFF, 0x00},
+		},
+		{
+			name:   "unsupported version",
+			header: []byte{'C', 'C', 3, byte(FrameTypeEnvelope), 0x00, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:   "unknown flags",
+			header: []byte{'C', 'C', FrameVersion, byte(FrameTypeEnvelope), 0x80, 0x00, 0x00, 0x00, 0x00, 0x00},
+		},
+		{
+			name:   "short header",
+			header: []byte{'C', 'C', FrameVersion},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := readFrameHeader(bytes.NewReader(test.header))
+			if err == nil {
+				t.Error("got nil error, wanted an error")
+			}
+		})
+	}
+}
+
+func TestNegotiateCapabilities(t *testing.T) {
+	tests := []struct {
+		name   string
+		local  []Capability
+		remote []Capability
+		want   []Capability
+	}{
+		{name: "same", local: []Capability{"a", "b"}, remote: []Capability{"b", "a"}, want: []Capability{"a", "b"}},
+		{name: "older remote", local: []Capability{"a", "b"}, remote: []Capability{"a"}, want: []Capability{"a"}},
+		{name: "newer remote", local: []Capability{"a"}, remote: []Capability{"a", "c"}, want: []Capability{"a"}},
+		{name: "none", local: []Capability{"a"}, remote: nil, want: []Capability{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := negotiateCapabilities(test.local, test.remote)
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, wanted %v", got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("got %v, wanted %v", got, test.want)
+				}
+			}
+		})
+	}
+}
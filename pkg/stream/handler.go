@@ -0,0 +1,211 @@
+package stream
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// errEndStream returned by a message handler to end the stream, the handler already updated the device status
+var errEndStream = errors.New("end stream")
+
+// messageHandler handle one kind of envelope payload, an error ends the stream
+type messageHandler func(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error
+
+// messageHandlers the handlers by the field name in the envelope payload oneof
+var messageHandlers = map[protoreflect.Name]messageHandler{}
+
+// registerMessageHandler register the handler of the envelope payload field
+func registerMessageHandler(name protoreflect.Name, handler messageHandler) {
+	messageHandlers[name] = handler
+}
+
+func init() {
+	registerMessageHandler("hello", handleHelloMessage)
+	registerMessageHandler("device_data", handleDeviceDataMessage)
+	registerMessageHandler("clipboard", handleClipboardMessage)
+	registerMessageHandler("signal", handleSignalMessage)
+	registerMessageHandler("ack", handleAckMessage)
+}
+
+// payloadName returns the field name of the envelope payload, or empty name if there is no payload
+func payloadName(envelope *protobuf.Envelope) protoreflect.Name {
+	m := envelope.ProtoReflect()
+	field := m.WhichOneof(m.Descriptor().Oneofs().ByName("payload"))
+	if field == nil {
+		return ""
+	}
+	return field.Name()
+}
+
+// dataPayloads the envelope payloads of the clipboard, sent on the data stream
+var dataPayloads = map[protoreflect.Name]bool{
+	"clipboard":         true,
+	"transfer_manifest": true,
+	"transfer_chunk":    true,
+}
+
+// isDataPayload returns true if the envelope carries the clipboard payload
+func isDataPayload(envelope *protobuf.Envelope) bool {
+	return dataPayloads[payloadName(envelope)]
+}
+
+// dispatch handle the envelope by the registered handler of the payload,
+// payloads from a newer version without a handler are ignored
+func (s *StreamHandler) dispatch(dv *device.Device, envelope *protobuf.Envelope) error {
+	name := payloadName(envelope)
+	handler, ok := messageHandlers[name]
+	if !ok {
+		s.logChan <- fmt.Sprintf("ignored unknown message %s %q from peer: %s", envelope.MessageId, name, dv.AddressInfo.ID.Loggable())
+		return nil
+	}
+	return handler(s, dv, envelope)
+}
+
+func handleHelloMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	s.handleHello(dv, envelope.GetHello())
+	return nil
+}
+
+func handleSignalMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	signal := envelope.GetSignal()
+	if signal != SignalPing && signal != SignalPong { // too frequent to log
+		s.logChan <- fmt.Sprintf("received signal %v, peer: %s", signal, dv.AddressInfo.ID.Loggable())
+	}
+	switch signal {
+	case SignalDisconnect:
+		dv.Status = device.StatusDisconnected
+		s.deviceManager.UpdateDevice(dv)
+		return errEndStream
+	case SignalRequestDeviceData:
+		s.SendDeviceData(dv)
+	case SignalPairingConfirmed:
+		dv.ConfirmPairing()
+		s.deviceManager.UpdateDevice(dv)
+		if dv.Status == device.StatusConnected {
+			s.deviceConnected(dv)
+		}
+	case SignalPing:
+		s.SendSignal(dv, SignalPong)
+	case SignalPong:
+		s.handlePong(dv)
+	default:
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored unknown signal %v from peer: %s", signal, dv.AddressInfo.ID.Loggable())
+	}
+	return nil
+}
+
+func handleClipboardMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	// only accept clipboard from the paired devices
+	if dv.Status != device.StatusConnected {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored clipboard data from %s device %s", dv.Status, dv.AddressInfo.ID.Loggable())
+		s.sendAck(dv, envelope.MessageId, protobuf.AckStatus_ACK_STATUS_FILTERED)
+		return nil
+	}
+
+	encrypted := envelope.GetClipboard()
+	// skip clipboard size when data more than config max size
+	if len(encrypted) > s.maxClipboardSize() {
+		s.errorChan <- xerror.NewRuntimeErrorf("data size %d > config max size %d", len(encrypted), s.maxClipboardSize())
+		s.sendAck(dv, envelope.MessageId, protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE)
+		return nil
+	}
+
+	clipboardData, err := s.decryptClipboardData(envelope.Compression, encrypted)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored clipboard data from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.sendAck(dv, envelope.MessageId, protobuf.AckStatus_ACK_STATUS_DECRYPT_FAILED)
+		return nil
+	}
+
+	// the replayed clipboard is dropped without the receipt, the receipt of the original one is already sent
+	if !s.acceptSequence(dv, envelope.MessageId, clipboardData.MessageId, clipboardData.Sequence) {
+		return nil
+	}
+
+	s.logChan <- fmt.Sprintf("received clipboard data, peer: %s size: %d", dv.AddressInfo.ID.Loggable(), clipboardData.DataSize)
+	s.sendAck(dv, envelope.MessageId, s.applyClipboard(dv, clipboard.FromProtobuf(clipboardData, dv)))
+	return nil
+}
+
+func handleDeviceDataMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	deviceData := envelope.GetDeviceData()
+	s.logChan <- fmt.Sprintf("received device data, peer: %s", dv.AddressInfo.ID.Loggable())
+	// the device is connected only after both devices confirmed the pairing, a device which never confirms can't be paired
+	if !dv.HasCapability(CapabilityPairingConfirm) {
+		return xerror.NewRuntimeErrorf("peer %s does not confirm the pairing, the device must be upgraded", dv.AddressInfo.ID.Loggable())
+	}
+
+	s.logChan <- fmt.Sprintf("%s wanted to connect", deviceData.Name)
+	oldPublicKey := dv.PublicKey
+	if dv.UpdateFromProtobuf(deviceData) {
+		s.errorChan <- xerror.NewRuntimeErrorf("public key of %s changed, refused the device until it's approved again", dv.AddressInfo.ID.Loggable())
+		s.auditKeyChanged(dv, oldPublicKey)
+	} else if !dv.IsTrusted() {
+		dv.Status = device.StatusPending
+
+		if reason, ok := s.autoTrustReason(dv, deviceData); ok {
+			err := s.TrustDevice(dv)
+			if err != nil {
+				s.errorChan <- xerror.NewRuntimeErrorf("can not trust %s by %s", deviceData.Name, reason).Wrap(err)
+			} else {
+				s.logChan <- fmt.Sprintf("trusted %s by %s", deviceData.Name, reason)
+			}
+		}
+	} else {
+		// the device may not trust this device yet, confirm the pairing for it
+		s.SendSignal(dv, SignalPairingConfirmed)
+		// the device is connected when it also confirmed the pairing
+		if dv.PeerConfirmed {
+			dv.Status = device.StatusConnected
+			s.deviceConnected(dv)
+		} else {
+			dv.Status = device.StatusPending
+		}
+	}
+
+	s.deviceManager.UpdateDevice(dv)
+	return nil
+}
+
+// deviceConnected continue the work with the device paired on both sides
+func (s *StreamHandler) deviceConnected(dv *device.Device) {
+	s.requestTransferResume(dv)
+	s.vouchInvitedDevices(dv)
+}
+
+func handleAckMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	ack := envelope.GetAck()
+	if s.transferSender.Complete(dv.AddressInfo.ID.String(), ack.MessageId) {
+		s.logChan <- fmt.Sprintf("transfer %s completed, peer: %s", ack.MessageId, dv.AddressInfo.ID.Loggable())
+	}
+
+	status := deliveryStatus(ack.Status)
+	if !s.clipboardManager.UpdateDelivery(dv, ack.MessageId, status) {
+		s.logChan <- fmt.Sprintf("received ack of untracked message %s, peer: %s", ack.MessageId, dv.AddressInfo.ID.Loggable())
+		return nil
+	}
+	s.logChan <- fmt.Sprintf("clipboard %s %s, peer: %s", ack.MessageId, status, dv.AddressInfo.ID.Loggable())
+	return nil
+}
+
+// deliveryStatus returns the delivery status of the ack status
+func deliveryStatus(status protobuf.AckStatus) clipboard.DeliveryStatus {
+	switch status {
+	case protobuf.AckStatus_ACK_STATUS_APPLIED:
+		return clipboard.DeliveryApplied
+	case protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE:
+		return clipboard.DeliveryRejectedTooLarge
+	case protobuf.AckStatus_ACK_STATUS_DECRYPT_FAILED:
+		return clipboard.DeliveryDecryptFailed
+	case protobuf.AckStatus_ACK_STATUS_FILTERED:
+		return clipboard.DeliveryFiltered
+	default:
+		return clipboard.DeliveryFailed
+	}
+}
@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestPayloadName(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload func(envelope *protobuf.Envelope)
+		want    protoreflect.Name
+	}{
+		{
+			name:    "hello",
+			payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Hello{Hello: &protobuf.Hello{}} },
+			want:    "hello",
+		},
+		{
+			name: "device data",
+			payload: func(e *protobuf.Envelope) {
+				e.Payload = &protobuf.Envelope_DeviceData{DeviceData: &protobuf.DeviceData{}}
+			},
+			want: "device_data",
+		},
+		{
+			name:    "clipboard",
+			payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Clipboard{Clipboard: []byte{1}} },
+			want:    "clipboard",
+		},
+		{
+			name:    "signal",
+			payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Signal{Signal: SignalDisconnect} },
+			want:    "signal",
+		},
+		{
+			name:    "ack",
+			payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Ack{Ack: &protobuf.Ack{}} },
+			want:    "ack",
+		},
+		{
+			name:    "no payload",
+			payload: func(e *protobuf.Envelope) {},
+			want:    "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envelope := newEnvelope()
+			test.payload(envelope)
+
+			// the payload must survive the wire
+			b, err := proto.Marshal(envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded := &protobuf.Envelope{}
+			if err := proto.Unmarshal(b, decoded); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := payloadName(decoded); got != test.want {
+				t.Errorf("got %q, wanted %q", got, test.want)
+			}
+			if _, ok := messageHandlers[test.want]; test.want != "" && !ok {
+				t.Errorf("no handler registered for %q", test.want)
+			}
+			if decoded.MessageId != envelope.MessageId || decoded.Timestamp != envelope.Timestamp {
+				t.Errorf("got id %s time %d, wanted id %s time %d", decoded.MessageId, decoded.Timestamp, envelope.MessageId, envelope.Timestamp)
+			}
+		})
+	}
+}
+
+func TestIsDataPayload(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload func(e *protobuf.Envelope)
+		want    bool
+	}{
+		{name: "clipboard", payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Clipboard{Clipboard: []byte{1}} }, want: true},
+		{name: "transfer manifest", payload: func(e *protobuf.Envelope) {
+			e.Payload = &protobuf.Envelope_TransferManifest{TransferManifest: []byte{1}}
+		}, want: true},
+		{name: "transfer chunk", payload: func(e *protobuf.Envelope) {
+			e.Payload = &protobuf.Envelope_TransferChunk{TransferChunk: &protobuf.TransferChunk{}}
+		}, want: true},
+		{name: "transfer resume", payload: func(e *protobuf.Envelope) {
+			e.Payload = &protobuf.Envelope_TransferResume{TransferResume: &protobuf.TransferResume{}}
+		}, want: false},
+		{name: "signal", payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Signal{Signal: SignalDisconnect} }, want: false},
+		{name: "ack", payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Ack{Ack: &protobuf.Ack{}} }, want: false},
+		{name: "hello", payload: func(e *protobuf.Envelope) { e.Payload = &protobuf.Envelope_Hello{Hello: &protobuf.Hello{}} }, want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			envelope := newEnvelope()
+			test.payload(envelope)
+			if got := isDataPayload(envelope); got != test.want {
+				t.Errorf("got %v, wanted %v", got, test.want)
+			}
+		})
+	}
+}
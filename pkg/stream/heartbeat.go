@@ -0,0 +1,116 @@
+package stream
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// heartbeat the liveness of a device stream, any message received from the device counts as alive
+type heartbeat struct {
+	lastReceived atomic.Int64 // unix nano of the last message received
+	pingSentAt   atomic.Int64 // unix nano of the ping waiting for the pong, 0 if there is none
+	expired      atomic.Bool  // nothing is received in the timeout, the reader of the device disconnects it
+
+	// stream the control stream of the heartbeat, only its connection is closed when it expires
+	stream *device.Stream
+	done   chan struct{}
+}
+
+// received record a message is received from the device
+func (hb *heartbeat) received() {
+	hb.lastReceived.Store(time.Now().UnixNano())
+}
+
+// stop stop the heartbeat loop
+func (hb *heartbeat) stop() {
+	close(hb.done)
+}
+
+// startHeartbeat ping the device every interval and disconnect the control stream when nothing is received in the timeout
+func (s *StreamHandler) startHeartbeat(dv *device.Device, cs *device.Stream) *heartbeat {
+	hb := &heartbeat{stream: cs, done: make(chan struct{})}
+	hb.received()
+	s.heartbeats.Store(dv, hb)
+
+	go s.heartbeatLoop(dv, hb)
+	return hb
+}
+
+// heartbeatReceived record a message is received from the device on any stream
+func (s *StreamHandler) heartbeatReceived(dv *device.Device) {
+	if v, ok := s.heartbeats.Load(dv); ok {
+		v.(*heartbeat).received()
+	}
+}
+
+func (s *StreamHandler) heartbeatLoop(dv *device.Device, hb *heartbeat) {
+	defer s.heartbeats.CompareAndDelete(dv, hb)
+
+	interval := time.Duration(s.config.Heartbeat.Interval) * time.Second
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	timeout := time.Duration(s.config.Heartbeat.Timeout) * time.Second
+	if timeout <= interval {
+		timeout = 3 * interval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-hb.done:
+			return
+		case <-ticker.C:
+		}
+
+		// the device can not reply the ping
+		if !dv.HasCapability(CapabilityHeartbeat) {
+			continue
+		}
+
+		now := time.Now()
+		if now.Sub(time.Unix(0, hb.lastReceived.Load())) > timeout {
+			s.errorChan <- xerror.NewRuntimeErrorf("peer %s missed heartbeat for %s, disconnecting", dv.AddressInfo.ID.Loggable(), timeout)
+			hb.expired.Store(true)
+			// close the stale connection so the device is redialed, the reader of the device ends and changes the status,
+			// the connection of a newer control stream is not touched
+			err := hb.stream.Conn().Close()
+			if err != nil {
+				s.errorChan <- xerror.NewRuntimeErrorf("can not close connection of peer %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+			}
+			return
+		}
+
+		// keep one ping in flight to measure the rtt by its pong
+		if hb.pingSentAt.CompareAndSwap(0, now.UnixNano()) {
+			// the status is not changed here, a broken stream ends the reader of the device
+			envelope := newEnvelope()
+			envelope.Payload = &protobuf.Envelope_Signal{Signal: SignalPing}
+			err := s.sendEnvelope(dv, envelope)
+			if err != nil {
+				s.errorChan <- xerror.NewRuntimeErrorf("cannot send ping to %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+			}
+		}
+	}
+}
+
+// handlePong record the rtt of the ping in flight
+func (s *StreamHandler) handlePong(dv *device.Device) {
+	v, ok := s.heartbeats.Load(dv)
+	if !ok {
+		return
+	}
+	hb := v.(*heartbeat)
+
+	sentAt := hb.pingSentAt.Swap(0)
+	if sentAt == 0 {
+		return
+	}
+	dv.SetRTT(time.Since(time.Unix(0, sentAt)))
+}
@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+)
+
+func TestHandlePong(t *testing.T) {
+	tests := []struct {
+		name       string
+		pingSentAt time.Duration // before now, 0 for no ping in flight
+		wantRTT    bool
+	}{
+		{name: "ping in flight", pingSentAt: 50 * time.Millisecond, wantRTT: true},
+		{name: "no ping in flight", wantRTT: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := &StreamHandler{logChan: make(chan string, 1)}
+			dv := &device.Device{}
+			hb := &heartbeat{done: make(chan struct{})}
+			if test.pingSentAt > 0 {
+				hb.pingSentAt.Store(time.Now().Add(-test.pingSentAt).UnixNano())
+			}
+			s.heartbeats.Store(dv, hb)
+
+			s.handlePong(dv)
+
+			if test.wantRTT && dv.RTT() < test.pingSentAt {
+				t.Errorf("got rtt %s, wanted at least %s", dv.RTT(), test.pingSentAt)
+			}
+			if !test.wantRTT && dv.RTT() != 0 {
+				t.Errorf("got rtt %s, wanted 0", dv.RTT())
+			}
+			if hb.pingSentAt.Load() != 0 {
+				t.Error("got ping still in flight after pong")
+			}
+		})
+	}
+}
+
+func TestHeartbeatTimeout(t *testing.T) {
+	s, peerHost, thisHost, errorChan := newPeerTestHandler(t, false)
+	s.config.Heartbeat = config.HeartbeatConfig{Interval: 1, Timeout: 2}
+
+	// the peer reads the pings but never replies
+	dv := newConnectedTestDevice(t, peerHost, thisHost)
+	dv.Capabilities = []string{CapabilityHeartbeat}
+	cs := dv.ControlStream
+
+	hb := s.startHeartbeat(dv, cs)
+
+	waitError(t, errorChan, "missed heartbeat")
+	if !hb.expired.Load() {
+		t.Error("the heartbeat is not expired")
+	}
+	if !cs.Conn().IsClosed() {
+		t.Error("the connection of the control stream is not closed")
+	}
+}
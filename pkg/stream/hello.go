@@ -0,0 +1,95 @@
+package stream
+
+import (
+	"fmt"
+
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// Capability a feature announced in the hello, a feature is used only when both devices support it
+type Capability = string
+
+const (
+	// CapabilityPairingConfirm the device sends the pairing confirmed signal, a device without it is refused
+	CapabilityPairingConfirm Capability = "pairing_confirm"
+	// CapabilityInvite the device presents the invite token to the issuer and accepts the vouch of the issuer
+	CapabilityInvite Capability = "invite"
+	// CapabilityChunkedTransfer large clipboard is sent as a transfer of chunks
+	CapabilityChunkedTransfer Capability = "chunked_transfer"
+	// CapabilityResumeTransfer the transfer is continued after reconnecting
+	CapabilityResumeTransfer Capability = "resume_transfer"
+	// CapabilityHeartbeat the device replies the ping
+	CapabilityHeartbeat Capability = "heartbeat"
+	// CapabilityCompressionZstd the device decompresses zstd data
+	CapabilityCompressionZstd Capability = "compression_zstd"
+	// CapabilityCompressionGzip the device decompresses gzip data
+	CapabilityCompressionGzip Capability = "compression_gzip"
+	// CapabilityFormats the transfer carries all representations of the clipboard, not only the data
+	CapabilityFormats Capability = "formats"
+	// CapabilityFiles the device receives the copied files
+	CapabilityFiles Capability = "files"
+	// CapabilityPrimarySelection the device tells the primary selection from the clipboard
+	CapabilityPrimarySelection Capability = "primary_selection"
+)
+
+// capabilities the features supported by this version
+var capabilities = []Capability{
+	CapabilityPairingConfirm,
+	CapabilityInvite,
+	CapabilityChunkedTransfer,
+	CapabilityResumeTransfer,
+	CapabilityHeartbeat,
+	CapabilityCompressionZstd,
+	CapabilityCompressionGzip,
+	CapabilityFormats,
+	CapabilityFiles,
+	CapabilityPrimarySelection,
+}
+
+// SendHello send the protocol version and the capabilities to device
+func (s *StreamHandler) SendHello(dv *device.Device) error {
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_Hello{
+		Hello: &protobuf.Hello{
+			Version:      uint32(FrameVersion),
+			Capabilities: capabilities,
+		},
+	}
+	return s.sendEnvelope(dv, envelope)
+}
+
+// handleHello record the capabilities supported by both devices,
+// the protocol version is already checked in the header of the frame
+func (s *StreamHandler) handleHello(dv *device.Device, hello *protobuf.Hello) {
+	dv.Capabilities = negotiateCapabilities(capabilities, hello.Capabilities)
+	s.logChan <- fmt.Sprintf("peer %s protocol version %d capabilities %v", dv.AddressInfo.ID.Loggable(), hello.Version, dv.Capabilities)
+}
+
+// negotiateCapabilities returns the local capabilities also supported by the remote
+func negotiateCapabilities(local, remote []Capability) []Capability {
+	remoteSet := make(map[Capability]struct{}, len(remote))
+	for _, c := range remote {
+		remoteSet[c] = struct{}{}
+	}
+
+	agreed := []Capability{}
+	for _, c := range local {
+		if _, ok := remoteSet[c]; ok {
+			agreed = append(agreed, c)
+		}
+	}
+	return agreed
+}
+
+// HandleLegacyStream handler when a peer connect this host with the protocol before the versioned frame,
+// the stream is closed because the old frame can not be understood
+func (s *StreamHandler) HandleLegacyStream(stream network.Stream) {
+	s.errorChan <- xerror.NewRuntimeErrorf(
+		"peer %s uses the old protocol %s, the device must be upgraded to use protocol %s",
+		stream.Conn().RemotePeer(), LEGACY_PROTOCAL_ID, CONTROL_PROTOCAL_ID,
+	)
+	stream.Reset()
+}
@@ -0,0 +1,230 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/invite"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+func init() {
+	registerMessageHandler("vouch", handleVouchMessage)
+}
+
+// vouch the public key of a device vouched for by the issuer of an invite token, valid until the token expires
+type vouch struct {
+	publicKey []byte
+	expiresAt int64
+}
+
+// SetJoinToken set the invite token of the group this device is joining,
+// it's sent to the issuer in device data and the issuer is trusted automatically
+func (s *StreamHandler) SetJoinToken(token *invite.Token, encoded string) {
+	s.joinMu.Lock()
+	defer s.joinMu.Unlock()
+	s.joinToken = token
+	s.joinTokenEncoded = encoded
+}
+
+// getJoinToken returns the invite token of the group this device is joining
+func (s *StreamHandler) getJoinToken() (*invite.Token, string) {
+	s.joinMu.Lock()
+	defer s.joinMu.Unlock()
+	return s.joinToken, s.joinTokenEncoded
+}
+
+// autoTrustReason returns the reason if the untrusted device can be trusted without asking the user
+func (s *StreamHandler) autoTrustReason(dv *device.Device, deviceData *protobuf.DeviceData) (string, bool) {
+	if s.config.AutoTrust {
+		return "auto trust", true
+	}
+
+	// the token is sent only by the device supporting the invite
+	if deviceData.InviteToken != "" && dv.HasCapability(CapabilityInvite) {
+		err := s.redeemInvite(dv, deviceData.InviteToken)
+		if err == nil {
+			return "invite token", true
+		}
+		s.errorChan <- xerror.NewRuntimeErrorf("can not accept invite token from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+	}
+
+	joinToken, _ := s.getJoinToken()
+	if joinToken != nil && joinToken.Issuer == dv.AddressInfo.ID.String() && dv.HasCapability(CapabilityInvite) {
+		return "invite issuer", true
+	}
+
+	if s.isVouched(dv) {
+		return "vouch of invite issuer", true
+	}
+
+	return "", false
+}
+
+// redeemInvite verify the invite token presented by the new device, the token must be issued by
+// this device and not redeemed by another device, the other members trust the device by the vouch of this device
+func (s *StreamHandler) redeemInvite(dv *device.Device, encoded string) error {
+	token, err := invite.DecodeToken(encoded)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = token.Verify(s.config.GroupName, now)
+	if err != nil {
+		return err
+	}
+
+	hostID, err := peer.IDFromPrivateKey(s.config.ID)
+	if err != nil {
+		return xerror.NewRuntimeError("can not get peer id of this device").Wrap(err)
+	}
+	if token.Issuer != hostID.String() {
+		return xerror.NewRuntimeErrorf("invite token is issued by %s, not this device", token.Issuer)
+	}
+
+	return s.inviteStore.Redeem(token, dv.AddressInfo.ID.String(), now)
+}
+
+// vouchInvitedDevices vouch for the devices joined by the invite tokens of this device to the connected device,
+// and vouch for the connected group members to the device if it joined by a token,
+// the members not connected when a device joined are vouched for when they connect before the token expires
+func (s *StreamHandler) vouchInvitedDevices(dv *device.Device) {
+	hostID, err := peer.IDFromPrivateKey(s.config.ID)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeError("can not get peer id of this device").Wrap(err)
+		return
+	}
+
+	for peerID, token := range s.inviteStore.Redeemed(hostID.String(), time.Now()) {
+		if peerID != dv.AddressInfo.ID.String() {
+			invited := s.deviceManager.GetDevice(peerID)
+			if invited == nil || !invited.IsTrusted() {
+				continue
+			}
+			s.sendVouch(dv, invited, token)
+			if invited.Status == device.StatusConnected {
+				s.sendVouch(invited, dv, token)
+			}
+			continue
+		}
+
+		for _, member := range s.deviceManager.ListDevices() {
+			if member == dv || member.Status != device.StatusConnected {
+				continue
+			}
+			s.sendVouch(member, dv, token)
+			s.sendVouch(dv, member, token)
+		}
+	}
+}
+
+// sendVouch send the vouch for the device to the other device with the invite token redeemed by the new device
+func (s *StreamHandler) sendVouch(to *device.Device, vouched *device.Device, token *invite.Token) {
+	if !to.HasCapability(CapabilityInvite) {
+		return
+	}
+
+	encoded, err := token.Encode()
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("cannot send vouch to %s", to.AddressInfo.ID.Loggable()).Wrap(err)
+		return
+	}
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_Vouch{
+		Vouch: &protobuf.Vouch{
+			PeerId:      vouched.AddressInfo.ID.String(),
+			PublicKey:   vouched.PublicKey,
+			InviteToken: encoded,
+		},
+	}
+	err = s.sendEnvelope(to, envelope)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("cannot send vouch to %s", to.AddressInfo.ID.Loggable()).Wrap(err)
+	}
+}
+
+func handleVouchMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	v := envelope.GetVouch()
+	if !dv.HasCapability(CapabilityInvite) {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored vouch from %s not supporting the invite", dv.AddressInfo.ID.Loggable())
+		return nil
+	}
+	// only accept vouch from the paired devices
+	if dv.Status != device.StatusConnected {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored vouch from %s device %s", dv.Status, dv.AddressInfo.ID.Loggable())
+		return nil
+	}
+
+	err := s.acceptVouch(dv, v)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored vouch for %s from %s", v.PeerId, dv.AddressInfo.ID.Loggable()).Wrap(err)
+		return nil
+	}
+	s.logChan <- fmt.Sprintf("received vouch for %s, peer: %s", v.PeerId, dv.AddressInfo.ID.Loggable())
+
+	// the device waiting for pairing is trusted now, others are trusted when they send the device data
+	vouched := s.deviceManager.GetDevice(v.PeerId)
+	if vouched == nil || vouched.Status != device.StatusPending || vouched.IsTrusted() || !s.isVouched(vouched) {
+		return nil
+	}
+	err = s.TrustDevice(vouched)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("can not trust %s by vouch of invite issuer", vouched.Name).Wrap(err)
+		return nil
+	}
+	s.logChan <- fmt.Sprintf("trusted %s by vouch of invite issuer", vouched.Name)
+	s.deviceManager.UpdateDevice(vouched)
+	return nil
+}
+
+// acceptVouch verify the vouch is sent by the issuer of the invite token and record the vouched public key,
+// a group member records the token redeemed by the vouched device, so it's redeemed once on every member
+func (s *StreamHandler) acceptVouch(dv *device.Device, v *protobuf.Vouch) error {
+	token, err := invite.DecodeToken(v.InviteToken)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = token.Verify(s.config.GroupName, now)
+	if err != nil {
+		return err
+	}
+	if token.Issuer != dv.AddressInfo.ID.String() {
+		return xerror.NewRuntimeErrorf("invite token is issued by %s, not the device", token.Issuer)
+	}
+
+	// this device joined by the token and the vouched device is a member, or the vouched device joined by the token
+	joinToken, _ := s.getJoinToken()
+	if joinToken == nil || joinToken.ID != token.ID {
+		err = s.inviteStore.Redeem(token, v.PeerId, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.joinMu.Lock()
+	s.vouches[v.PeerId] = vouch{
+		publicKey: v.PublicKey,
+		expiresAt: token.ExpiresAt,
+	}
+	s.joinMu.Unlock()
+	return nil
+}
+
+// isVouched returns true if the public key of the device is vouched for by an invite issuer and the token is not expired
+func (s *StreamHandler) isVouched(dv *device.Device) bool {
+	s.joinMu.Lock()
+	defer s.joinMu.Unlock()
+
+	v, ok := s.vouches[dv.AddressInfo.ID.String()]
+	if !ok || time.Now().Unix() > v.expiresAt {
+		return false
+	}
+	return bytes.Equal(v.publicKey, dv.PublicKey)
+}
@@ -0,0 +1,204 @@
+package stream
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/transfer"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// outboxItem a clipboard waiting to be sent to a device
+type outboxItem struct {
+	clipboard *clipboard.Clipboard
+	archive   *filesArchive // the archive of the copied files sent instead of the clipboard, nil for other clipboards
+	messageID string
+}
+
+// outbox bounded queue of the clipboards to send to a device, drained by a writer goroutine of the device,
+// the oldest clipboard is dropped when it's full as only the newest clipboard matters
+type outbox struct {
+	limit int
+
+	mu      sync.Mutex
+	queue   []*outboxItem
+	stopped bool
+	notify  chan struct{}
+
+	// stream the control stream of the outbox, it's reset when sending fails
+	stream *device.Stream
+	// failed sending failed, the reader of the device changes it to the error status
+	failed atomic.Bool
+}
+
+func newOutbox(limit int, cs *device.Stream) *outbox {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &outbox{
+		limit:  limit,
+		notify: make(chan struct{}, 1),
+		stream: cs,
+	}
+}
+
+// push add the item to the queue, returns the dropped items when the queue is full or stopped
+func (ob *outbox) push(item *outboxItem) []*outboxItem {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if ob.stopped {
+		return []*outboxItem{item}
+	}
+
+	var dropped []*outboxItem
+	ob.queue = append(ob.queue, item)
+	if len(ob.queue) > ob.limit {
+		n := len(ob.queue) - ob.limit
+		dropped = append(dropped, ob.queue[:n]...)
+		ob.queue = append([]*outboxItem{}, ob.queue[n:]...)
+	}
+
+	select {
+	case ob.notify <- struct{}{}:
+	default:
+	}
+	return dropped
+}
+
+// pop wait for the next item, returns false when the outbox is stopped
+func (ob *outbox) pop() (*outboxItem, bool) {
+	for {
+		ob.mu.Lock()
+		if ob.stopped {
+			ob.mu.Unlock()
+			return nil, false
+		}
+		if len(ob.queue) > 0 {
+			item := ob.queue[0]
+			ob.queue = ob.queue[1:]
+			ob.mu.Unlock()
+			return item, true
+		}
+		ob.mu.Unlock()
+
+		<-ob.notify
+	}
+}
+
+// stop stop the outbox and returns the items not sent
+func (ob *outbox) stop() []*outboxItem {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+
+	if ob.stopped {
+		return nil
+	}
+	ob.stopped = true
+	remaining := ob.queue
+	ob.queue = nil
+	close(ob.notify)
+	return remaining
+}
+
+// depth returns the number of items waiting to be sent
+func (ob *outbox) depth() int {
+	ob.mu.Lock()
+	defer ob.mu.Unlock()
+	return len(ob.queue)
+}
+
+// startOutbox create the outbox of the device stream and start its writer goroutine
+func (s *StreamHandler) startOutbox(dv *device.Device, cs *device.Stream) *outbox {
+	ob := newOutbox(s.config.MaxQueue, cs)
+
+	s.outboxMu.Lock()
+	s.outboxes[dv.AddressInfo.ID] = ob
+	s.outboxMu.Unlock()
+
+	go s.runOutbox(dv, ob)
+	return ob
+}
+
+// stopOutbox stop the outbox of the device stream, the clipboards not sent are marked as dropped
+func (s *StreamHandler) stopOutbox(dv *device.Device, ob *outbox) {
+	s.outboxMu.Lock()
+	if s.outboxes[dv.AddressInfo.ID] == ob {
+		delete(s.outboxes, dv.AddressInfo.ID)
+	}
+	s.outboxMu.Unlock()
+
+	s.dropOutboxItems(dv, ob.stop())
+}
+
+// enqueue add the clipboard to the outbox of the device
+func (s *StreamHandler) enqueue(dv *device.Device, item *outboxItem) {
+	s.outboxMu.Lock()
+	ob, ok := s.outboxes[dv.AddressInfo.ID]
+	s.outboxMu.Unlock()
+	if !ok {
+		s.dropOutboxItems(dv, []*outboxItem{item})
+		return
+	}
+
+	dropped := ob.push(item)
+	if len(dropped) > 0 {
+		s.logChan <- fmt.Sprintf("send queue of peer %s is full, dropped %d older clipboards", dv.AddressInfo.ID.Loggable(), len(dropped))
+	}
+	s.dropOutboxItems(dv, dropped)
+}
+
+// dropOutboxItems mark the clipboards not sent as dropped
+func (s *StreamHandler) dropOutboxItems(dv *device.Device, items []*outboxItem) {
+	for _, item := range items {
+		if item.archive != nil {
+			item.archive.release()
+		}
+		s.clipboardManager.UpdateDelivery(dv, item.messageID, clipboard.DeliveryDropped)
+	}
+}
+
+// runOutbox send the clipboards in the outbox to the device until the outbox is stopped
+func (s *StreamHandler) runOutbox(dv *device.Device, ob *outbox) {
+	for {
+		item, ok := ob.pop()
+		if !ok {
+			return
+		}
+
+		name := dv.AddressInfo.ID.String()
+		cb := item.clipboard
+		s.logChan <- fmt.Sprintf("sending data to peer: %s len: %d queue: %d", name, cb.TotalSize(), ob.depth())
+
+		var err error
+		if item.archive != nil {
+			err = s.sendFilesTransfer(dv, item.messageID, item.archive)
+			item.archive.release()
+		} else if cb.TotalSize() > transfer.DefaultChunkSize {
+			err = s.sendTransfer(dv, item.messageID, cb)
+		} else {
+			err = s.sendClipboardData(dv, item.messageID, cb.ToProtobuf())
+		}
+		if err != nil {
+			s.errorChan <- xerror.NewRuntimeErrorf("error to send data for peer: %s", name).Wrap(err)
+			s.clipboardManager.UpdateDelivery(dv, item.messageID, clipboard.DeliveryFailed)
+			// end the control stream, the reader of the device changes the status
+			ob.failed.Store(true)
+			ob.stream.Reset()
+		}
+	}
+}
+
+// QueueDepth returns the number of clipboards waiting to be sent to the device
+func (s *StreamHandler) QueueDepth(dv *device.Device) int {
+	s.outboxMu.Lock()
+	ob, ok := s.outboxes[dv.AddressInfo.ID]
+	s.outboxMu.Unlock()
+	if !ok {
+		return 0
+	}
+	return ob.depth()
+}
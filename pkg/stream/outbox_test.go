@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutboxDropOldest(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		push        []string
+		wantDropped []string
+		wantQueue   []string
+	}{
+		{
+			name:      "not full",
+			limit:     3,
+			push:      []string{"a", "b"},
+			wantQueue: []string{"a", "b"},
+		},
+		{
+			name:        "full",
+			limit:       2,
+			push:        []string{"a", "b", "c", "d"},
+			wantDropped: []string{"a", "b"},
+			wantQueue:   []string{"c", "d"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ob := newOutbox(test.limit, nil)
+			dropped := []string{}
+			for _, id := range test.push {
+				for _, item := range ob.push(&outboxItem{messageID: id}) {
+					dropped = append(dropped, item.messageID)
+				}
+			}
+
+			if len(dropped) != len(test.wantDropped) {
+				t.Fatalf("got dropped %v, wanted %v", dropped, test.wantDropped)
+			}
+			for i := range dropped {
+				if dropped[i] != test.wantDropped[i] {
+					t.Errorf("got dropped %v, wanted %v", dropped, test.wantDropped)
+				}
+			}
+
+			if ob.depth() != len(test.wantQueue) {
+				t.Fatalf("got depth %d, wanted %d", ob.depth(), len(test.wantQueue))
+			}
+			for _, want := range test.wantQueue {
+				item, ok := ob.pop()
+				if !ok || item.messageID != want {
+					t.Errorf("got %v %v, wanted %s", item, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestOutboxStop(t *testing.T) {
+	ob := newOutbox(2, nil)
+
+	popped := make(chan bool)
+	go func() {
+		_, ok := ob.pop()
+		popped <- ok
+	}()
+
+	// the blocked pop returns the pushed item
+	ob.push(&outboxItem{messageID: "a"})
+	select {
+	case ok := <-popped:
+		if !ok {
+			t.Fatal("got pop stopped, wanted item")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop is blocked after push")
+	}
+
+	go func() {
+		_, ok := ob.pop()
+		popped <- ok
+	}()
+	remaining := ob.stop()
+	if len(remaining) != 0 {
+		t.Errorf("got %d remaining items, wanted 0", len(remaining))
+	}
+	select {
+	case ok := <-popped:
+		if ok {
+			t.Error("got item after stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pop is blocked after stop")
+	}
+
+	// the item pushed after stop is dropped
+	if dropped := ob.push(&outboxItem{messageID: "b"}); len(dropped) != 1 {
+		t.Errorf("got %d dropped items after stop, wanted 1", len(dropped))
+	}
+}
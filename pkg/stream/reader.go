@@ -2,131 +2,212 @@ package stream
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"io"
 
 	"github.com/libp2p/go-libp2p/core/network"
-	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 )
 
-const limitDataSize = 1 << 20 // data size to avoid to read (100 MB)
+const limitDataSize = 1 << 20 // frame size to avoid to read (1 MiB), larger clipboard is sent in chunks
 
 // CreateReadData craete a new read streaming for host or peer
 func (s *StreamHandler) CreateReadData(reader *bufio.Reader, dv *device.Device) {
+	// the control stream of this reader, it's replaced when the device connects again
+	cs := dv.ControlStream
+
+	err := s.SendHello(dv)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("cannot send hello to %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.endReadData(dv, cs, device.StatusError)
+		return
+	}
+
 	s.logChan <- fmt.Sprintf("sending device info and public key to %s", dv.AddressInfo.ID.Loggable())
 
-	s.sendDeviceData(dv)
+	s.SendDeviceData(dv)
+
+	// the first frame from the device must be the hello
+	helloReceived := false
+
+	hb := s.startHeartbeat(dv, cs)
+	ob := s.startOutbox(dv, cs)
 
 	// loop for incoming message
+	var status device.DeviceStatus
 disconnect:
 	for {
-		dataSize, err := readDataSize(reader)
+		envelope, errStatus, err := s.readEnvelope(dv, reader)
 		if err != nil {
-			if err == network.ErrReset { // error stream reset because it unusual stream end
-				s.errorChan <- xerror.NewRuntimeErrorf("peer %s stream reset", dv.AddressInfo.ID.Loggable()).Wrap(err)
-				dv.Status = device.StatusDisconnected
-				s.deviceManager.UpdateDevice(dv)
-				break disconnect
-			}
-
-			s.errorChan <- xerror.NewRuntimeError("error reading data size").Wrap(err)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
+			s.errorChan <- err
+			status = errStatus
 			break disconnect
 		}
 
-		if dataSize <= 0 {
-			s.errorChan <- xerror.NewRuntimeErrorf("data size < 0, size %d", dataSize)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
-			break disconnect
+		if !helloReceived {
+			if envelope.GetHello() == nil {
+				s.errorChan <- xerror.NewRuntimeErrorf("peer %s sent %q before hello", dv.AddressInfo.ID.Loggable(), payloadName(envelope))
+				status = device.StatusError
+				break disconnect
+			}
+			helloReceived = true
 		}
 
-		// avoid to read big data from stream
-		if dataSize > limitDataSize {
-			s.errorChan <- xerror.NewRuntimeErrorf("data size %d > limit data size %d", dataSize, limitDataSize)
-			dv.Status = device.StatusBlocked
-			s.deviceManager.UpdateDevice(dv)
+		err = s.dispatch(dv, envelope)
+		if errors.Is(err, errEndStream) {
 			break disconnect
 		}
-
-		// skip clipboard size when data more than config max size
-		if dataSize > s.config.MaxSize {
-			s.errorChan <- xerror.NewRuntimeErrorf("data size %d > config max size %d", dataSize, s.config.MaxSize)
-			reader.Discard(dataSize)
-			continue
-		}
-
-		buffer := make([]byte, dataSize)
-		readBytes, err := io.ReadFull(reader, buffer)
 		if err != nil {
-			s.errorChan <- xerror.NewRuntimeError("error reading from buffer").Wrap(err)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
-			break disconnect
-		}
-		if readBytes != dataSize {
-			s.errorChan <- xerror.NewRuntimeErrorf("not reading full bytes read: %d size: %d", readBytes, dataSize)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
+			s.errorChan <- xerror.NewRuntimeErrorf("error handling %q from peer %s", payloadName(envelope), dv.AddressInfo.ID.Loggable()).Wrap(err)
+			status = device.StatusError
 			break disconnect
 		}
+	}
 
-		clipboardData, deviceData, signal, err := s.decodeData(buffer)
-		if err != nil {
-			s.errorChan <- xerror.NewRuntimeError("error decoding data").Wrap(err)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
-			break disconnect
-		}
+	s.logChan <- fmt.Sprintf("ending read stream for peer: %s", dv.AddressInfo.ID.Loggable())
 
-		if signal != nil {
-			s.logChan <- fmt.Sprintf("received signal %v, peer: %s", signal, dv.AddressInfo.ID.Loggable())
-			switch *signal {
-			case SignalDisconnect:
-				dv.Status = device.StatusDisconnected
-				s.deviceManager.UpdateDevice(dv)
-				break disconnect
-			case SignalRequestDeviceData:
-				s.sendDeviceData(dv)
-			}
-		}
+	hb.stop()
+	s.stopOutbox(dv, ob)
+	// the connection is closed by the heartbeat, the device is redialed
+	if hb.expired.Load() {
+		status = device.StatusDisconnected
+	}
+	// the stream is reset by the outbox when sending failed
+	if ob.failed.Load() {
+		status = device.StatusError
+	}
+
+	// the unfinished transfer is kept to resume after reconnecting when the device supports it
+	if !dv.HasCapability(CapabilityResumeTransfer) {
+		s.transferReceiver.Abort(dv.AddressInfo.ID.String())
+	}
+
+	s.endReadData(dv, cs, status)
+}
 
-		if clipboardData != nil {
-			s.clipboardManager.WriteClipboard(clipboard.FromProtobuf(clipboardData, dv))
-			s.logChan <- fmt.Sprintf("received clipboard data, peer: %s size: %d", dv.AddressInfo.ID.Loggable(), clipboardData.DataSize)
+// endReadData change the device to the status and close its streams, empty status keeps the status,
+// the device connected again with a new control stream is not changed, only the old stream is closed
+func (s *StreamHandler) endReadData(dv *device.Device, cs *device.Stream, status device.DeviceStatus) {
+	if dv.ControlStream != cs {
+		cs.Close()
+		return
+	}
+	if status != "" {
+		dv.Status = status
+		s.deviceManager.UpdateDevice(dv)
+	}
+	s.closeStream(dv)
+}
+
+// readEnvelope read the next frame from the stream and decode the envelope,
+// an error ends the stream and the device is changed to the returned status
+func (s *StreamHandler) readEnvelope(dv *device.Device, reader *bufio.Reader) (*protobuf.Envelope, device.DeviceStatus, error) {
+	header, err := readFrameHeader(reader)
+	if err != nil {
+		if err == network.ErrReset { // error stream reset because it unusual stream end
+			return nil, device.StatusDisconnected, xerror.NewRuntimeErrorf("peer %s stream reset", dv.AddressInfo.ID.Loggable()).Wrap(err)
 		}
+		return nil, device.StatusError, xerror.NewRuntimeError("error reading frame header").Wrap(err)
+	}
+	s.heartbeatReceived(dv)
+	dataSize := header.Length
 
-		if deviceData != nil {
-			s.logChan <- fmt.Sprintf("received device data, peer: %s", dv.AddressInfo.ID.Loggable())
+	// avoid to read big data from stream
+	if dataSize > limitDataSize {
+		return nil, device.StatusBlocked, xerror.NewRuntimeErrorf("data size %d > limit data size %d", dataSize, limitDataSize)
+	}
 
-			s.logChan <- fmt.Sprintf("%s wanted to connect", deviceData.Name)
-			dv.UpdateFromProtobuf(deviceData)
+	buffer := make([]byte, dataSize)
+	readBytes, err := io.ReadFull(reader, buffer)
+	if err != nil {
+		return nil, device.StatusError, xerror.NewRuntimeError("error reading from buffer").Wrap(err)
+	}
+	if readBytes != dataSize {
+		return nil, device.StatusError, xerror.NewRuntimeErrorf("not reading full bytes read: %d size: %d", readBytes, dataSize)
+	}
 
-			if dv.PgpEncrypter == nil {
-				dv.Status = device.StatusPending
+	envelope, err := s.decodeEnvelope(header, buffer)
+	if err != nil {
+		return nil, device.StatusError, xerror.NewRuntimeError("error decoding data").Wrap(err)
+	}
+	return envelope, "", nil
+}
 
-				if s.config.AutoTrust {
-					dv.Trust()
-					s.logChan <- fmt.Sprintf("trusted %s by auto trust", deviceData.Name)
-				}
-			} else {
-				dv.Status = device.StatusConnected
+// readDataStream read the clipboard payloads from the data stream of the device,
+// the device is disconnected when the data stream ends before the control stream
+func (s *StreamHandler) readDataStream(dv *device.Device, ds *device.Stream) {
+	status := device.StatusDisconnected
+	for {
+		envelope, errStatus, err := s.readEnvelope(dv, ds.Reader)
+		if err != nil {
+			if dv.DataStream() == ds { // not closed by this device
+				s.errorChan <- err
 			}
+			status = errStatus
+			break
+		}
 
-			s.deviceManager.UpdateDevice(dv)
+		if !isDataPayload(envelope) {
+			s.errorChan <- xerror.NewRuntimeErrorf("peer %s sent %q on the data stream", dv.AddressInfo.ID.Loggable(), payloadName(envelope))
+			status = device.StatusError
+			break
+		}
+
+		err = s.dispatch(dv, envelope)
+		if err != nil {
+			s.errorChan <- xerror.NewRuntimeErrorf("error handling %q from peer %s", payloadName(envelope), dv.AddressInfo.ID.Loggable()).Wrap(err)
+			status = device.StatusError
+			break
 		}
 	}
 
-	s.logChan <- fmt.Sprintf("ending read stream for peer: %s", dv.AddressInfo.ID.Loggable())
+	s.logChan <- fmt.Sprintf("ending data stream for peer: %s", dv.AddressInfo.ID.Loggable())
+
+	// the data stream is already removed when the control stream ended or a new data stream is attached
+	if !dv.ClearDataStream(ds) {
+		return
+	}
+	ds.Close()
+	dv.Status = status
+	s.deviceManager.UpdateDevice(dv)
+	// end the control stream too, the device is reconnected with both streams
+	dv.ControlStream.Close()
+}
 
-	err := dv.Stream.Close()
+// closeStream close the control stream and the data stream of the device
+func (s *StreamHandler) closeStream(dv *device.Device) {
+	err := dv.CloseStreams()
 	if err != nil {
-		if err == network.ErrReset { // check stream already reset
+		if errors.Is(err, network.ErrReset) { // check stream already reset
 			s.logChan <- fmt.Sprintf("peer %s stream already reset", dv.AddressInfo.ID.Loggable())
 		}
 		s.errorChan <- fmt.Errorf("can not close stream for peer %s: %w", dv.AddressInfo.ID, err)
 	}
 }
+
+// auditKeyChanged write the changed public key of the device to the audit log
+func (s *StreamHandler) auditKeyChanged(dv *device.Device, oldPublicKey []byte) {
+	details := map[string]string{
+		"name":           dv.Name,
+		"oldFingerprint": fingerprint(oldPublicKey),
+		"newFingerprint": fingerprint(dv.PendingPublicKey),
+	}
+	err := s.auditLogger.Log(audit.EventKeyChanged, dv.AddressInfo.ID.String(), details)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeError("can not write key changed event to audit log").Wrap(err)
+	}
+}
+
+// fingerprint returns the fingerprint of the pgp public key, or empty string if the key is invalid
+func fingerprint(publicKey []byte) string {
+	key, err := crypto.ByteToPGPKey(publicKey)
+	if err != nil {
+		return ""
+	}
+	return key.GetFingerprint()
+}
@@ -0,0 +1,46 @@
+package stream
+
+import (
+	"strconv"
+
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// nextSequence returns the next sequence number of the message sent by this device,
+// the sequence starts from the time in microseconds so it keeps increasing after restarting
+func (s *StreamHandler) nextSequence() uint64 {
+	return s.sequence.Add(1)
+}
+
+// acceptSequence returns true if the encrypted message is not replayed, the message id in the encrypted payload
+// must be the envelope message id, the rejected message is written to the audit log
+func (s *StreamHandler) acceptSequence(dv *device.Device, envelopeMessageID string, messageID string, sequence uint64) bool {
+	var err error
+	if messageID != envelopeMessageID {
+		err = xerror.NewRuntimeErrorf("message id %q is not the envelope message id %q", messageID, envelopeMessageID)
+	} else {
+		err = s.replayFilter.Accept(dv.AddressInfo.ID.String(), sequence)
+	}
+	if err == nil {
+		// the sequence number is kept to reject the message after restarting
+		saveErr := s.replayFilter.Save()
+		if saveErr != nil {
+			s.errorChan <- xerror.NewRuntimeError("can not save replay sequence numbers").Wrap(saveErr)
+		}
+		return true
+	}
+
+	s.errorChan <- xerror.NewRuntimeErrorf("rejected replayed message %s from %s", envelopeMessageID, dv.AddressInfo.ID.Loggable()).Wrap(err)
+	auditErr := s.auditLogger.Log(audit.EventReplayRejected, dv.AddressInfo.ID.String(), map[string]string{
+		"name":      dv.Name,
+		"messageId": envelopeMessageID,
+		"sequence":  strconv.FormatUint(sequence, 10),
+		"reason":    err.Error(),
+	})
+	if auditErr != nil {
+		s.errorChan <- xerror.NewRuntimeError("can not write replay rejected event to audit log").Wrap(auditErr)
+	}
+	return false
+}
@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/replay"
+)
+
+func TestAcceptSequence(t *testing.T) {
+	tests := []struct {
+		name       string
+		messageID  string
+		sequence   uint64
+		wantAccept bool
+	}{
+		{name: "new message", messageID: "m2", sequence: 11, wantAccept: true},
+		{name: "replayed message", messageID: "m2", sequence: 10, wantAccept: false},
+		{name: "message id mismatch", messageID: "m1", sequence: 12, wantAccept: false},
+		{name: "no sequence", messageID: "m2", sequence: 0, wantAccept: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			replayFilter, err := replay.NewFilter(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := &StreamHandler{
+				errorChan:    make(chan error, 1),
+				auditLogger:  audit.NewLogger(dir),
+				replayFilter: replayFilter,
+			}
+			dv := &device.Device{Name: "device"}
+			if !s.acceptSequence(dv, "m1", "m1", 10) {
+				t.Fatal("first message is rejected")
+			}
+
+			if got := s.acceptSequence(dv, "m2", test.messageID, test.sequence); got != test.wantAccept {
+				t.Errorf("got accept %v, wanted %v", got, test.wantAccept)
+			}
+
+			b, err := os.ReadFile(filepath.Join(dir, "audit.log"))
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			logged := strings.Contains(string(b), string(audit.EventReplayRejected))
+			if logged == test.wantAccept {
+				t.Errorf("got audit logged %v, wanted %v", logged, !test.wantAccept)
+			}
+		})
+	}
+}
@@ -1,17 +1,24 @@
 package stream
 
 import (
-	"bufio"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	"github.com/multiformats/go-multiaddr"
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
 	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
 	"github.com/yqs112358/cross-clipboard/pkg/config"
 	"github.com/yqs112358/cross-clipboard/pkg/crypto"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 	"github.com/yqs112358/cross-clipboard/pkg/devicemanager"
+	"github.com/yqs112358/cross-clipboard/pkg/invite"
+	"github.com/yqs112358/cross-clipboard/pkg/replay"
+	"github.com/yqs112358/cross-clipboard/pkg/transfer"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 )
 
 // StreamHandler struct for stream handler
@@ -23,6 +30,35 @@ type StreamHandler struct {
 	errorChan        chan error
 
 	pgpDecrypter *crypto.PGPDecrypter
+	inviteStore  *invite.Store
+	auditLogger  *audit.Logger
+
+	transferReceiver *transfer.Receiver
+	transferSender   *transfer.Sender
+
+	// sequence the sequence number of the last message sent, replayFilter the sequence numbers received
+	sequence     atomic.Uint64
+	replayFilter *replay.Filter
+
+	// heartbeats the heartbeat of each device stream
+	heartbeats sync.Map
+
+	outboxMu sync.Mutex
+	outboxes map[peer.ID]*outbox
+
+	// sendGeneration counts the clipboards sent, the files packed after another clipboard is sent are dropped
+	sendMu         sync.Mutex
+	sendGeneration uint64
+
+	// pendingDataStreams the data streams accepted before the control stream of the peer
+	dataStreamMu       sync.Mutex
+	pendingDataStreams map[peer.ID]network.Stream
+
+	joinMu           sync.Mutex
+	joinToken        *invite.Token
+	joinTokenEncoded string
+	// vouches the public keys of the devices vouched for by the invite issuers by peer id
+	vouches map[string]vouch
 }
 
 // NewStreamHandler initial new stream handler
@@ -33,6 +69,9 @@ func NewStreamHandler(
 	logChan chan string,
 	errorChan chan error,
 	pgpDecrypter *crypto.PGPDecrypter,
+	inviteStore *invite.Store,
+	replayFilter *replay.Filter,
+	auditLogger *audit.Logger,
 ) *StreamHandler {
 	s := &StreamHandler{
 		config:           cfg,
@@ -41,27 +80,132 @@ func NewStreamHandler(
 		logChan:          logChan,
 		errorChan:        errorChan,
 		pgpDecrypter:     pgpDecrypter,
+		inviteStore:      inviteStore,
+		auditLogger:      auditLogger,
+		transferSender:   transfer.NewSender(""),
+		replayFilter:     replayFilter,
+		outboxes:         make(map[peer.ID]*outbox),
+
+		pendingDataStreams: make(map[peer.ID]network.Stream),
+		vouches:            make(map[string]vouch),
 	}
+	s.transferReceiver = transfer.NewReceiver("", s.maxClipboardSize()+clipboardDataOverhead)
+	s.sequence.Store(uint64(time.Now().UnixMicro()))
 	go s.CreateWriteData()
+	go s.expireTransfers()
 	return s
 }
 
-// HandleStream handler when a peer connect this host
+// HandleStream handler when a peer connect this host with the control stream,
+// a known device keeps its pinned public key, trust and address book, a blocked device is refused
 func (s *StreamHandler) HandleStream(stream network.Stream) {
-	s.logChan <- fmt.Sprintf("peer %s connecting to this host", stream.Conn().RemotePeer())
+	peerID := stream.Conn().RemotePeer()
+	s.logChan <- fmt.Sprintf("peer %s connecting to this host", peerID)
 
-	// Create a new peer
-	dv := device.NewDevice(peer.AddrInfo{
-		ID:    stream.Conn().RemotePeer(),
-		Addrs: []multiaddr.Multiaddr{stream.Conn().RemoteMultiaddr()},
-	}, stream)
+	s.dataStreamMu.Lock()
+	dv := s.deviceManager.GetDevice(peerID.String())
+	if dv != nil && dv.Status == device.StatusBlocked {
+		if dataStream, ok := s.pendingDataStreams[peerID]; ok {
+			delete(s.pendingDataStreams, peerID)
+			dataStream.Reset()
+		}
+		s.dataStreamMu.Unlock()
+		s.errorChan <- xerror.NewRuntimeErrorf("refused stream from blocked device %s", peerID.Loggable())
+		stream.Reset()
+		return
+	}
 
-	dv.Reader = bufio.NewReader(stream)
-	dv.Writer = bufio.NewWriter(stream)
-	s.deviceManager.AddDevice(dv)
+	addrInfo := peer.AddrInfo{
+		ID:    peerID,
+		Addrs: []multiaddr.Multiaddr{stream.Conn().RemoteMultiaddr()},
+	}
+	if dv == nil {
+		// Create a new peer
+		dv = device.NewDevice(addrInfo, stream)
+		s.deviceManager.AddDevice(dv)
+	} else {
+		// the readers of the old streams end without changing the device,
+		// the address book keeps the dialable addresses rather than the inbound one
+		if !dv.ReplaceControlStream(stream) {
+			// both devices dialed each other, the stream opened by this host is kept
+			if dataStream, ok := s.pendingDataStreams[peerID]; ok {
+				delete(s.pendingDataStreams, peerID)
+				dataStream.Reset()
+			}
+			s.dataStreamMu.Unlock()
+			s.logChan <- fmt.Sprintf("peer %s dialed this host at the same time, keep the stream of this host", peerID.Loggable())
+			stream.Reset()
+			return
+		}
+		dv.UpdateAddressBook(nil)
+		s.deviceManager.UpdateDevice(dv)
+	}
+	dataStream, ok := s.pendingDataStreams[peerID]
+	delete(s.pendingDataStreams, peerID)
+	s.dataStreamMu.Unlock()
 
-	go s.CreateReadData(dv.Reader, dv)
+	go s.CreateReadData(dv.ControlStream.Reader, dv)
+	if ok {
+		s.AttachDataStream(dv, dataStream)
+	}
 
 	s.logChan <- fmt.Sprintf("peer %s connected to this host", stream.Conn().RemotePeer())
 	// 'stream' will stay open until you close it (or the other side closes it).
 }
+
+// pendingDataStreamTimeout the time to wait for the control stream of a data stream accepted first
+const pendingDataStreamTimeout = 10 * time.Second
+
+// HandleDataStream handler when a peer open the data stream to this host,
+// the stream is attached to the device of the control stream on the same connection
+func (s *StreamHandler) HandleDataStream(stream network.Stream) {
+	peerID := stream.Conn().RemotePeer()
+
+	s.dataStreamMu.Lock()
+	dv := s.deviceManager.GetDevice(peerID.String())
+	if dv != nil && dv.Status == device.StatusBlocked {
+		s.dataStreamMu.Unlock()
+		stream.Reset()
+		return
+	}
+	if dv != nil && dv.KeepsControlStream(peerID) {
+		// the data stream of a control stream that lost the simultaneous dial
+		s.dataStreamMu.Unlock()
+		stream.Reset()
+		return
+	}
+	if dv == nil || dv.ControlStream == nil || dv.ControlStream.Conn() != stream.Conn() {
+		// the control stream is handled concurrently, attach the data stream when it's accepted
+		if old, ok := s.pendingDataStreams[peerID]; ok {
+			old.Reset()
+		}
+		s.pendingDataStreams[peerID] = stream
+		s.dataStreamMu.Unlock()
+
+		time.AfterFunc(pendingDataStreamTimeout, func() {
+			s.dataStreamMu.Lock()
+			defer s.dataStreamMu.Unlock()
+			if s.pendingDataStreams[peerID] == stream {
+				delete(s.pendingDataStreams, peerID)
+				stream.Reset()
+			}
+		})
+		return
+	}
+	s.dataStreamMu.Unlock()
+
+	s.AttachDataStream(dv, stream)
+}
+
+// AttachDataStream set the data stream of the device and start reading it
+func (s *StreamHandler) AttachDataStream(dv *device.Device, stream network.Stream) {
+	ds := device.NewStream(stream)
+	if old := dv.DataStream(); old != nil {
+		dv.ClearDataStream(old)
+		old.Close()
+	}
+	dv.SetDataStream(ds)
+	s.logChan <- fmt.Sprintf("peer %s data stream opened", dv.AddressInfo.ID.Loggable())
+
+	go s.readDataStream(dv, ds)
+}
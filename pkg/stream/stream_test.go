@@ -0,0 +1,406 @@
+package stream
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	mocknet "github.com/libp2p/go-libp2p/p2p/net/mock"
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/devicemanager"
+	"github.com/yqs112358/cross-clipboard/pkg/invite"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/replay"
+)
+
+// testPublicKey returns the armored public key of a new pgp key
+func testPublicKey(t *testing.T) []byte {
+	armored, err := crypto.GeneratePGPKey("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := crypto.UnmarshalPGPKey(armored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := key.GetPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return publicKey
+}
+
+// newPeerTestHandler create the stream handler of a host handling the control streams of the other host
+func newPeerTestHandler(t *testing.T, autoTrust bool) (*StreamHandler, host.Host, host.Host, chan error) {
+	mn, err := mocknet.FullMeshConnected(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the readers of the handler may still write the devices file while the network is closed
+	dir, err := os.MkdirTemp("", "stream-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		mn.Close()
+		os.RemoveAll(dir)
+	})
+	hosts := mn.Hosts()
+
+	armored, err := crypto.GeneratePGPKey("this device")
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, err := crypto.UnmarshalPGPKey(armored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypter, err := crypto.NewPGPDecrypter(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{
+		ID:            id,
+		ConfigDirPath: dir,
+		PGPPrivateKey: privKey,
+		AutoTrust:     autoTrust,
+		MaxSize:       5 << 20,
+		MaxHistory:    10,
+		MaxQueue:      4,
+	}
+	inviteStore, err := invite.NewStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayFilter, err := replay.NewFilter(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logChan := make(chan string)
+	go func() {
+		for range logChan {
+		}
+	}()
+	errorChan := make(chan error, 10)
+	s := NewStreamHandler(
+		cfg,
+		clipboard.NewClipboardManager(cfg, clipboard.NewMemoryBackend(), nil),
+		devicemanager.NewDeviceManager(cfg),
+		logChan,
+		errorChan,
+		decrypter,
+		inviteStore,
+		replayFilter,
+		audit.NewLogger(dir),
+	)
+	hosts[1].SetStreamHandler(CONTROL_PROTOCAL_ID, s.HandleStream)
+	return s, hosts[0], hosts[1], errorChan
+}
+
+// newConnectedTestDevice create the device of the peer host with the control stream opened by this host,
+// the peer host discards what it receives
+func newConnectedTestDevice(t *testing.T, peerHost host.Host, thisHost host.Host) *device.Device {
+	peerHost.SetStreamHandler(CONTROL_PROTOCAL_ID, func(stream network.Stream) {
+		io.Copy(io.Discard, stream)
+	})
+	stream, err := thisHost.NewStream(context.Background(), peerHost.ID(), CONTROL_PROTOCAL_ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stream.Close() })
+	return device.NewDevice(peer.AddrInfo{ID: peerHost.ID()}, stream)
+}
+
+// waitError returns the first error containing the text
+func waitError(t *testing.T, errorChan chan error, text string) {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case err := <-errorChan:
+			if strings.Contains(err.Error(), text) {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for error %q", text)
+		}
+	}
+}
+
+func TestHandleStreamReconnect(t *testing.T) {
+	pinnedKey := testPublicKey(t)
+	newKey := testPublicKey(t)
+
+	tests := []struct {
+		name       string
+		status     device.DeviceStatus
+		wantError  string
+		wantStatus device.DeviceStatus
+		wantReset  bool
+	}{
+		{
+			name:       "blocked peer reconnects",
+			status:     device.StatusBlocked,
+			wantError:  "refused stream from blocked device",
+			wantStatus: device.StatusBlocked,
+			wantReset:  true,
+		},
+		{
+			name:       "known peer reconnects with a different key",
+			status:     device.StatusDisconnected,
+			wantError:  "public key of",
+			wantStatus: device.StatusKeyChanged,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// auto trust must not trust the new key of a known device
+			s, peerHost, thisHost, errorChan := newPeerTestHandler(t, true)
+
+			known := &device.Device{Name: "known"}
+			known.AddressInfo.ID = peerHost.ID()
+			known.PublicKey = pinnedKey
+			err := known.CreatePGPEncrypter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			known.PeerConfirmed = true
+			known.Status = test.status
+			s.deviceManager.AddDevice(known)
+
+			stream, err := peerHost.NewStream(context.Background(), thisHost.ID(), CONTROL_PROTOCAL_ID)
+			if err != nil {
+				// the stream of the blocked device may be reset while the protocol is negotiated
+				if test.wantReset && strings.Contains(err.Error(), "stream reset") {
+					waitError(t, errorChan, test.wantError)
+					return
+				}
+				t.Fatal(err)
+			}
+			defer stream.Close()
+			readDone := make(chan error, 1)
+			go func() {
+				_, err := io.Copy(io.Discard, stream)
+				readDone <- err
+			}()
+
+			// the peer says hello and presents the new key
+			for _, payload := range []func(e *protobuf.Envelope){
+				func(e *protobuf.Envelope) {
+					e.Payload = &protobuf.Envelope_Hello{Hello: &protobuf.Hello{Version: uint32(FrameVersion), Capabilities: capabilities}}
+				},
+				func(e *protobuf.Envelope) {
+					e.Payload = &protobuf.Envelope_DeviceData{DeviceData: &protobuf.DeviceData{Name: "known", PublicKey: newKey}}
+				},
+			} {
+				envelope := newEnvelope()
+				payload(envelope)
+				frame, err := s.encodeEnvelope(envelope)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stream.Write(frame)
+			}
+
+			waitError(t, errorChan, test.wantError)
+
+			dv := s.deviceManager.GetDevice(peerHost.ID().String())
+			if dv != known {
+				t.Fatal("got a new device record, wanted the known device")
+			}
+			if dv.Status != test.wantStatus {
+				t.Errorf("got status %s, wanted %s", dv.Status, test.wantStatus)
+			}
+			if !bytes.Equal(dv.PublicKey, pinnedKey) {
+				t.Error("the pinned public key is replaced")
+			}
+			if test.wantStatus == device.StatusKeyChanged && !bytes.Equal(dv.PendingPublicKey, newKey) {
+				t.Error("the new key is not pending for approval")
+			}
+
+			if test.wantReset {
+				select {
+				case err := <-readDone:
+					if err == nil {
+						t.Error("got stream closed, wanted reset")
+					}
+				case <-time.After(5 * time.Second):
+					t.Error("the stream of the blocked device is not reset")
+				}
+			}
+		})
+	}
+}
+
+func TestHandleDeviceDataPeerConfirmed(t *testing.T) {
+	publicKey := testPublicKey(t)
+
+	tests := []struct {
+		name          string
+		capabilities  []Capability
+		peerConfirmed bool
+		wantErr       bool
+		wantStatus    device.DeviceStatus
+	}{
+		{
+			name:          "trusted device confirmed the pairing",
+			capabilities:  capabilities,
+			peerConfirmed: true,
+			wantStatus:    device.StatusConnected,
+		},
+		{
+			name:         "trusted device didn't confirm the pairing",
+			capabilities: capabilities,
+			wantStatus:   device.StatusPending,
+		},
+		{
+			name:          "device not supporting the pairing confirmation",
+			capabilities:  []Capability{CapabilityInvite},
+			peerConfirmed: true,
+			wantErr:       true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, peerHost, thisHost, _ := newPeerTestHandler(t, false)
+			dv := newConnectedTestDevice(t, peerHost, thisHost)
+			dv.PublicKey = publicKey
+			err := dv.CreatePGPEncrypter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv.PeerConfirmed = test.peerConfirmed
+			dv.Capabilities = test.capabilities
+			s.deviceManager.AddDevice(dv)
+
+			envelope := newEnvelope()
+			envelope.Payload = &protobuf.Envelope_DeviceData{DeviceData: &protobuf.DeviceData{Name: "known", PublicKey: publicKey}}
+			err = handleDeviceDataMessage(s, dv, envelope)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, test.wantErr)
+			}
+			if dv.Status != test.wantStatus {
+				t.Errorf("got status %s, wanted %s", dv.Status, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandleVouchMessage(t *testing.T) {
+	publicKey := testPublicKey(t)
+	senderKey, _, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKey, _, err := crypto.NewKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		senderStatus device.DeviceStatus
+		noInvite     bool
+		otherIssuer  bool
+		redeemedBy   string
+		wantVouched  bool
+	}{
+		{
+			name:         "vouch from the issuer",
+			senderStatus: device.StatusConnected,
+			wantVouched:  true,
+		},
+		{
+			name:         "vouch from a device not the issuer",
+			senderStatus: device.StatusConnected,
+			otherIssuer:  true,
+		},
+		{
+			name:         "vouch from a device not supporting the invite",
+			senderStatus: device.StatusConnected,
+			noInvite:     true,
+		},
+		{
+			name:         "vouch from a device not paired",
+			senderStatus: device.StatusPending,
+		},
+		{
+			name:         "token already redeemed by another device",
+			senderStatus: device.StatusConnected,
+			redeemedBy:   "other-peer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _, _, _ := newPeerTestHandler(t, false)
+			sender := &device.Device{Status: test.senderStatus, Capabilities: capabilities}
+			if test.noInvite {
+				sender.Capabilities = []Capability{CapabilityPairingConfirm}
+			}
+			sender.AddressInfo.ID, err = peer.IDFromPrivateKey(senderKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			issuerKey := senderKey
+			if test.otherIssuer {
+				issuerKey = otherKey
+			}
+			token, err := invite.NewToken(issuerKey, s.config.GroupName, nil, time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.redeemedBy != "" {
+				err := s.inviteStore.Redeem(token, test.redeemedBy, time.Now())
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			encoded, err := token.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vouchedKey, _, err := crypto.NewKeyPair()
+			if err != nil {
+				t.Fatal(err)
+			}
+			vouched := &device.Device{PublicKey: publicKey}
+			vouched.AddressInfo.ID, err = peer.IDFromPrivateKey(vouchedKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envelope := newEnvelope()
+			envelope.Payload = &protobuf.Envelope_Vouch{
+				Vouch: &protobuf.Vouch{PeerId: vouched.AddressInfo.ID.String(), PublicKey: publicKey, InviteToken: encoded},
+			}
+			err = handleVouchMessage(s, sender, envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.isVouched(vouched); got != test.wantVouched {
+				t.Errorf("got vouched %v, wanted %v", got, test.wantVouched)
+			}
+		})
+	}
+}
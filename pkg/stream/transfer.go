@@ -0,0 +1,311 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/transfer"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+	"google.golang.org/protobuf/proto"
+)
+
+func init() {
+	registerMessageHandler("transfer_manifest", handleTransferManifestMessage)
+	registerMessageHandler("transfer_chunk", handleTransferChunkMessage)
+	registerMessageHandler("transfer_resume", handleTransferResumeMessage)
+}
+
+// expireTransfers drop the partial transfers not resumed in the resume expiry
+func (s *StreamHandler) expireTransfers() {
+	expiry := time.Duration(s.config.Transfer.ResumeExpiry) * time.Second
+	if expiry <= 0 {
+		expiry = 10 * time.Minute
+	}
+	interval := time.Minute
+	if expiry < interval {
+		interval = expiry
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		before := time.Now().Add(-expiry)
+		s.transferReceiver.Expire(before)
+		s.transferSender.Expire(before)
+	}
+}
+
+// sendTransfer send the clipboard as a manifest and a sequence of chunks, each chunk is compressed and encrypted separately
+// and cached to resume the transfer after reconnecting, the transfer id is the message id of the manifest
+func (s *StreamHandler) sendTransfer(dv *device.Device, transferID string, cb *clipboard.Clipboard) error {
+	// the transfer data is the clipboard data with the other representations when the device supports them
+	data := cb.Data
+	if dv.HasCapability(CapabilityFormats) {
+		var err error
+		data, err = proto.Marshal(cb.ToProtobuf())
+		if err != nil {
+			return xerror.NewRuntimeError("error marshaling clipboard data").Wrap(err)
+		}
+	}
+
+	manifest := transfer.NewManifest(transferID, data, cb.IsImage, cb.Time, transfer.DefaultChunkSize)
+	return s.sendTransferData(dv, manifest, data, bytes.NewReader(data))
+}
+
+// sendFilesTransfer send the archive of the copied files as a transfer, the archive is read from its temp file,
+// so the device extracts it from the spooled transfer
+func (s *StreamHandler) sendFilesTransfer(dv *device.Device, transferID string, archive *filesArchive) error {
+	manifest, err := transfer.ReadManifest(transferID, archive.reader(), false, archive.time, transfer.DefaultChunkSize)
+	if err != nil {
+		return err
+	}
+	manifest.MimeType = clipboard.MimeFiles
+
+	// the compression is chosen by the first chunk of the archive
+	head := make([]byte, min(archive.size, transfer.DefaultChunkSize))
+	_, err = archive.file.ReadAt(head, 0)
+	if err != nil {
+		return xerror.NewRuntimeError("can not read files archive").Wrap(err)
+	}
+	return s.sendTransferData(dv, manifest, head, archive.reader())
+}
+
+// sendTransferData cache the chunks of the data read from r and send the manifest and the chunks,
+// the compression is chosen by the sample of the data
+func (s *StreamHandler) sendTransferData(dv *device.Device, manifest *protobuf.TransferManifest, sample []byte, r io.Reader) error {
+	manifest.Compression = s.compressionFor(dv, sample)
+	manifest.Sequence = s.nextSequence()
+
+	manifestBytes, err := proto.Marshal(manifest)
+	if err != nil {
+		return xerror.NewRuntimeError("error marshaling transfer manifest").Wrap(err)
+	}
+	encryptedManifest, err := dv.PgpEncrypter.EncryptMessage(manifestBytes)
+	if err != nil {
+		return xerror.NewRuntimeError("error to encrypt transfer manifest").Wrap(err)
+	}
+
+	err = s.transferSender.CacheFrom(dv.AddressInfo.ID.String(), manifest, r, func(chunk []byte) ([]byte, error) {
+		compressed, err := s.compress(manifest.Compression, chunk)
+		if err != nil {
+			return nil, xerror.NewRuntimeError("error to compress transfer chunk").Wrap(err)
+		}
+		return dv.PgpEncrypter.EncryptMessage(compressed)
+	})
+	if err != nil {
+		return err
+	}
+
+	envelope := newEnvelope()
+	envelope.MessageId = manifest.TransferId
+	envelope.Payload = &protobuf.Envelope_TransferManifest{TransferManifest: encryptedManifest}
+	err = s.sendEnvelope(dv, envelope)
+	if err != nil {
+		return err
+	}
+
+	return s.sendTransferChunks(dv, manifest, 0)
+}
+
+// sendTransferChunks send the cached chunks of the transfer from the index
+func (s *StreamHandler) sendTransferChunks(dv *device.Device, manifest *protobuf.TransferManifest, from uint32) error {
+	peerID := dv.AddressInfo.ID.String()
+	for i := from; i < manifest.ChunkCount; i++ {
+		encryptedChunk, err := s.transferSender.ReadChunk(peerID, manifest.TransferId, i)
+		if err != nil {
+			return err
+		}
+
+		envelope := newEnvelope()
+		envelope.Compression = manifest.Compression
+		envelope.Payload = &protobuf.Envelope_TransferChunk{
+			TransferChunk: &protobuf.TransferChunk{
+				TransferId: manifest.TransferId,
+				Index:      i,
+				Data:       encryptedChunk,
+			},
+		}
+		err = s.sendEnvelope(dv, envelope)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requestTransferResume ask the device to continue the partial transfer received before the stream dropped
+func (s *StreamHandler) requestTransferResume(dv *device.Device) {
+	if !dv.HasCapability(CapabilityResumeTransfer) {
+		return
+	}
+	transferID, next, ok := s.transferReceiver.Pending(dv.AddressInfo.ID.String())
+	if !ok {
+		return
+	}
+
+	s.logChan <- fmt.Sprintf("requesting to resume transfer %s from chunk %d, peer: %s", transferID, next, dv.AddressInfo.ID.Loggable())
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_TransferResume{
+		TransferResume: &protobuf.TransferResume{
+			TransferId: transferID,
+			NextIndex:  next,
+		},
+	}
+	err := s.sendEnvelope(dv, envelope)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("cannot send transfer resume to %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+	}
+}
+
+func handleTransferManifestMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	// the transfer id is the message id of the manifest
+	transferID := envelope.MessageId
+
+	// only accept clipboard from the paired devices
+	if dv.Status != device.StatusConnected {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored transfer from %s device %s", dv.Status, dv.AddressInfo.ID.Loggable())
+		s.sendAck(dv, transferID, protobuf.AckStatus_ACK_STATUS_FILTERED)
+		return nil
+	}
+
+	manifestBytes, err := s.pgpDecrypter.DecryptMessage(envelope.GetTransferManifest())
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.sendAck(dv, transferID, protobuf.AckStatus_ACK_STATUS_DECRYPT_FAILED)
+		return nil
+	}
+	manifest := &protobuf.TransferManifest{}
+	err = proto.Unmarshal(manifestBytes, manifest)
+	if err != nil {
+		return xerror.NewRuntimeError("error unmarshaling transfer manifest").Wrap(err)
+	}
+
+	// the replayed transfer is dropped without the receipt, the receipt of the original one is already sent
+	if !s.acceptSequence(dv, transferID, manifest.TransferId, manifest.Sequence) {
+		return nil
+	}
+
+	if manifest.Size > uint64(s.maxClipboardSize()+clipboardDataOverhead) {
+		s.errorChan <- xerror.NewRuntimeErrorf("transfer size %d > config max size %d", manifest.Size, s.maxClipboardSize())
+		s.sendAck(dv, transferID, protobuf.AckStatus_ACK_STATUS_REJECTED_TOO_LARGE)
+		return nil
+	}
+
+	err = s.transferReceiver.Start(dv.AddressInfo.ID.String(), manifest)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("ignored transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.sendAck(dv, transferID, protobuf.AckStatus_ACK_STATUS_FAILED)
+		return nil
+	}
+	s.logChan <- fmt.Sprintf("receiving transfer %s, peer: %s size: %d chunks: %d", manifest.TransferId, dv.AddressInfo.ID.Loggable(), manifest.Size, manifest.ChunkCount)
+	return nil
+}
+
+func handleTransferChunkMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	chunk := envelope.GetTransferChunk()
+	peerID := dv.AddressInfo.ID.String()
+
+	if dv.Status != device.StatusConnected {
+		s.transferReceiver.Abort(peerID)
+		return nil
+	}
+
+	data, err := s.pgpDecrypter.DecryptMessage(chunk.Data)
+	if err != nil {
+		s.transferReceiver.Abort(peerID)
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s, can not decrypt chunk %d", dv.AddressInfo.ID.Loggable(), chunk.Index).Wrap(err)
+		s.sendAck(dv, chunk.TransferId, protobuf.AckStatus_ACK_STATUS_DECRYPT_FAILED)
+		return nil
+	}
+	data, err = decompress(envelope.Compression, data, transfer.MaxChunkSize)
+	if err != nil {
+		s.transferReceiver.Abort(peerID)
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s, can not decompress chunk %d", dv.AddressInfo.ID.Loggable(), chunk.Index).Wrap(err)
+		s.sendAck(dv, chunk.TransferId, protobuf.AckStatus_ACK_STATUS_FAILED)
+		return nil
+	}
+
+	done, err := s.transferReceiver.AddChunk(peerID, chunk.TransferId, chunk.Index, data)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.sendAck(dv, chunk.TransferId, protobuf.AckStatus_ACK_STATUS_FAILED)
+		return nil
+	}
+	if !done {
+		return nil
+	}
+
+	spool, manifest, err := s.transferReceiver.Finish(peerID)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		s.sendAck(dv, chunk.TransferId, protobuf.AckStatus_ACK_STATUS_FAILED)
+		return nil
+	}
+	defer spool.Close()
+
+	s.logChan <- fmt.Sprintf("received clipboard data, peer: %s size: %d", dv.AddressInfo.ID.Loggable(), manifest.Size)
+
+	// the receipt also let the sender drop the cached chunks
+	s.sendAck(dv, manifest.TransferId, s.applyTransfer(dv, spool, manifest))
+	return nil
+}
+
+// applyTransfer write the clipboard of the completed transfer, the files are extracted from the spooled transfer
+// and the clipboard is read to memory, returns the status of the receipt
+func (s *StreamHandler) applyTransfer(dv *device.Device, spool io.Reader, manifest *protobuf.TransferManifest) protobuf.AckStatus {
+	switch manifest.MimeType {
+	case clipboard.MimeFiles:
+		return s.applyFiles(dv, spool, time.Unix(manifest.Time, 0))
+	case "":
+	default:
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s, unsupported format %s", dv.AddressInfo.ID.Loggable(), manifest.MimeType)
+		return protobuf.AckStatus_ACK_STATUS_FAILED
+	}
+
+	data := make([]byte, manifest.Size)
+	_, err := io.ReadFull(spool, data)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+		return protobuf.AckStatus_ACK_STATUS_FAILED
+	}
+
+	clipboardData := &protobuf.ClipboardData{
+		IsImage:  manifest.IsImage,
+		Data:     data,
+		DataSize: uint32(manifest.Size),
+		Time:     time.Unix(manifest.Time, 0).UnixMicro(),
+	}
+	if dv.HasCapability(CapabilityFormats) {
+		clipboardData = &protobuf.ClipboardData{}
+		err = proto.Unmarshal(data, clipboardData)
+		if err != nil {
+			s.errorChan <- xerror.NewRuntimeErrorf("dropped transfer from %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+			return protobuf.AckStatus_ACK_STATUS_FAILED
+		}
+	}
+	return s.applyClipboard(dv, clipboard.FromProtobuf(clipboardData, dv))
+}
+
+func handleTransferResumeMessage(s *StreamHandler, dv *device.Device, envelope *protobuf.Envelope) error {
+	resume := envelope.GetTransferResume()
+	manifest, ok := s.transferSender.Manifest(dv.AddressInfo.ID.String(), resume.TransferId)
+	if !ok {
+		s.errorChan <- xerror.NewRuntimeErrorf("can not resume transfer %s to %s, it's expired or replaced", resume.TransferId, dv.AddressInfo.ID.Loggable())
+		return nil
+	}
+
+	s.logChan <- fmt.Sprintf("resuming transfer %s from chunk %d, peer: %s", resume.TransferId, resume.NextIndex, dv.AddressInfo.ID.Loggable())
+	// send in background to keep reading the stream
+	go func() {
+		err := s.sendTransferChunks(dv, manifest, resume.NextIndex)
+		if err != nil {
+			s.errorChan <- xerror.NewRuntimeErrorf("can not resume transfer %s to %s", resume.TransferId, dv.AddressInfo.ID.Loggable()).Wrap(err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,215 @@
+package stream
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/device"
+	"github.com/yqs112358/cross-clipboard/pkg/files"
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/transfer"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestHandleTransfer(t *testing.T) {
+	srcDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(srcDir, "a.txt"), bytes.Repeat([]byte("a"), 3000), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var archive bytes.Buffer
+	_, err = files.Pack(&archive, []string{filepath.Join(srcDir, "a.txt")}, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := bytes.Repeat([]byte("hello "), 500)
+	copied := clipboard.NewClipboard([]clipboard.Format{
+		{MimeType: clipboard.MimeTextPlain, Data: text},
+		{MimeType: clipboard.MimeTextHTML, Data: []byte("<b>hello</b>")},
+	}, time.Now())
+	clipboardData, err := proto.Marshal(copied.ToProtobuf())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		mimeType string
+		want     func(t *testing.T, received *clipboard.Clipboard, downloadDir string)
+	}{
+		{
+			name: "clipboard",
+			data: clipboardData,
+			want: func(t *testing.T, received *clipboard.Clipboard, _ string) {
+				if !bytes.Equal(received.Data, text) {
+					t.Errorf("got text of %d bytes, wanted %d bytes", len(received.Data), len(text))
+				}
+				if html, ok := received.Format(clipboard.MimeTextHTML); !ok || string(html) != "<b>hello</b>" {
+					t.Errorf("got html %q, wanted %q", html, "<b>hello</b>")
+				}
+			},
+		},
+		{
+			name:     "files",
+			data:     archive.Bytes(),
+			mimeType: clipboard.MimeFiles,
+			want: func(t *testing.T, received *clipboard.Clipboard, downloadDir string) {
+				uriList, _ := received.Format(clipboard.MimeURIList)
+				paths, ok := files.ParseURIList(uriList)
+				if !ok || len(paths) != 1 || filepath.Dir(paths[0]) != downloadDir {
+					t.Fatalf("got uri list %q, wanted the file in %s", uriList, downloadDir)
+				}
+				data, err := os.ReadFile(paths[0])
+				if err != nil || len(data) != 3000 {
+					t.Errorf("got file of %d bytes %v, wanted 3000 bytes", len(data), err)
+				}
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, peerHost, thisHost, errorChan := newPeerTestHandler(t, false)
+			s.config.Files = config.FilesConfig{Enabled: true, DownloadDir: t.TempDir(), MaxSize: 1 << 20}
+
+			// the device encrypts the transfer to this device
+			dv := newConnectedTestDevice(t, peerHost, thisHost)
+			publicKey, err := s.config.PGPPrivateKey.GetPublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv.PublicKey = publicKey
+			err = dv.CreatePGPEncrypter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv.Status = device.StatusConnected
+			dv.Capabilities = []Capability{CapabilityChunkedTransfer, CapabilityFormats, CapabilityFiles}
+
+			manifest := transfer.NewManifest(newMessageID(), test.data, false, time.Now(), 1024)
+			manifest.MimeType = test.mimeType
+			manifest.Sequence = 1
+			manifestBytes, err := proto.Marshal(manifest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			encryptedManifest, err := dv.PgpEncrypter.EncryptMessage(manifestBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			envelope := newEnvelope()
+			envelope.MessageId = manifest.TransferId
+			envelope.Payload = &protobuf.Envelope_TransferManifest{TransferManifest: encryptedManifest}
+			err = handleTransferManifestMessage(s, dv, envelope)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := uint32(0); i < manifest.ChunkCount; i++ {
+				encryptedChunk, err := dv.PgpEncrypter.EncryptMessage(transfer.Chunk(test.data, manifest, i))
+				if err != nil {
+					t.Fatal(err)
+				}
+				envelope := newEnvelope()
+				envelope.Payload = &protobuf.Envelope_TransferChunk{
+					TransferChunk: &protobuf.TransferChunk{TransferId: manifest.TransferId, Index: i, Data: encryptedChunk},
+				}
+				err = handleTransferChunkMessage(s, dv, envelope)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			select {
+			case err := <-errorChan:
+				t.Fatal(err)
+			default:
+			}
+			history := s.clipboardManager.ClipboardsHistory
+			if len(history) != 1 {
+				t.Fatalf("got %d clipboards, wanted the received one", len(history))
+			}
+			test.want(t, history[0], s.config.Files.DownloadDir)
+		})
+	}
+}
+
+func TestSendTransferCompression(t *testing.T) {
+	archive := bytes.Repeat([]byte("file "), 1000)
+
+	tests := []struct {
+		name            string
+		formats         []clipboard.Format
+		archive         []byte
+		wantCompression protobuf.Compression
+	}{
+		{
+			name:            "files archive without text",
+			archive:         archive,
+			wantCompression: protobuf.Compression_COMPRESSION_ZSTD,
+		},
+		{
+			name: "small text with large html",
+			formats: []clipboard.Format{
+				{MimeType: clipboard.MimeTextPlain, Data: []byte("hello")},
+				{MimeType: clipboard.MimeTextHTML, Data: bytes.Repeat([]byte("<b>hello</b>"), 1000)},
+			},
+			wantCompression: protobuf.Compression_COMPRESSION_ZSTD,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, peerHost, thisHost, _ := newPeerTestHandler(t, false)
+			s.config.Compression = config.CompressionConfig{Enabled: true, Level: 3, MinSize: 512}
+
+			dv := newConnectedTestDevice(t, peerHost, thisHost)
+			publicKey, err := s.config.PGPPrivateKey.GetPublicKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv.PublicKey = publicKey
+			err = dv.CreatePGPEncrypter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			dv.Capabilities = []Capability{CapabilityChunkedTransfer, CapabilityFormats, CapabilityFiles, CapabilityCompressionZstd}
+
+			transferID := newMessageID()
+			if test.archive != nil {
+				file, err := os.CreateTemp(t.TempDir(), "archive")
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer file.Close()
+				_, err = file.Write(test.archive)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = s.sendFilesTransfer(dv, transferID, &filesArchive{file: file, size: int64(len(test.archive)), time: time.Now()})
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else {
+				err = s.sendTransfer(dv, transferID, clipboard.NewClipboard(test.formats, time.Now()))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			manifest, ok := s.transferSender.Manifest(dv.AddressInfo.ID.String(), transferID)
+			if !ok {
+				t.Fatal("the transfer is not cached")
+			}
+			if manifest.Compression != test.wantCompression {
+				t.Errorf("got compression %s, wanted %s", manifest.Compression, test.wantCompression)
+			}
+		})
+	}
+}
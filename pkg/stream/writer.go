@@ -2,39 +2,32 @@ package stream
 
 import (
 	"bufio"
-	"fmt"
 	"runtime"
-	"time"
 
+	"github.com/yqs112358/cross-clipboard/pkg/audit"
 	"github.com/yqs112358/cross-clipboard/pkg/clipboard"
 	"github.com/yqs112358/cross-clipboard/pkg/device"
 	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/transfer"
 	"github.com/yqs112358/cross-clipboard/pkg/xerror"
 )
 
-// CreateWriteData handle clipboad channel and write to all peers and host
+// CreateWriteData handle clipboad channel and queue the clipboard to all peers, each peer is written by its own goroutine
 func (s *StreamHandler) CreateWriteData() {
 	// waiting for clipboard data
-readClipboardLoop:
-	for {
-		select {
-		case textBytes, ok := <-s.clipboardManager.ReadTextChannel:
-			if !ok {
-				break readClipboardLoop
-			}
-			s.sendClipboard(textBytes, false)
-		case imageBytes, ok := <-s.clipboardManager.ReadImageChannel:
-			if !ok {
-				break readClipboardLoop
-			}
-			s.sendClipboard(imageBytes, true)
-		}
+	for cb := range s.clipboardManager.ReadChannel {
+		s.sendClipboard(cb)
 	}
 	s.logChan <- "ended write streams"
 }
 
-func (s *StreamHandler) sendClipboard(clipboardBytes []byte, isImage bool) {
-	clipboardLength := len(clipboardBytes)
+// sendClipboard queue the clipboard with all its representations to all peers,
+// the copied files are packed by another goroutine so the clipboards copied meanwhile are not delayed
+func (s *StreamHandler) sendClipboard(cb *clipboard.Clipboard) {
+	// the received files are written as the uri list
+	isReceivedClipboard := s.clipboardManager.IsReceivedClipboard(cb.Data)
+
+	clipboardLength := cb.TotalSize()
 	if clipboardLength == 0 {
 		// ignore empty clipboard data
 		s.logChan <- "the clipboard is empty, ignoring"
@@ -46,22 +39,56 @@ func (s *StreamHandler) sendClipboard(clipboardBytes []byte, isImage bool) {
 		return
 	}
 
-	cb := &clipboard.Clipboard{
-		IsImage: isImage,
-		Data:    clipboardBytes,
-		Size:    uint32(clipboardLength),
-		Time:    time.Now(),
-	}
-
-	isReceivedClipboard := s.clipboardManager.IsReceivedClipboard(clipboardBytes)
+	// the history keeps the uri list of the copied files, not the archive
 	if !isReceivedClipboard { // the clipboard come from this device
 		s.clipboardManager.AddClipboardToHistory(cb)
 	}
 
-	clipboardData := cb.ToProtobuf()
+	s.sendMu.Lock()
+	s.sendGeneration++
+	generation := s.sendGeneration
+	paths, ok := s.copiedFiles(cb)
+	if !ok {
+		s.queueClipboard(cb, nil, isReceivedClipboard)
+		s.sendMu.Unlock()
+		return
+	}
+	s.sendMu.Unlock()
+
+	go s.sendFiles(cb, paths, generation, isReceivedClipboard)
+}
+
+// sendFiles pack the copied files of the clipboard and queue the archive to all peers,
+// the archive is dropped when another clipboard is copied while packing
+func (s *StreamHandler) sendFiles(cb *clipboard.Clipboard, paths []string, generation uint64, isReceivedClipboard bool) {
+	archive, err := s.packFiles(paths, cb.Time)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeError("can not send the copied files").Wrap(err)
+		return
+	}
+	// the transfers queued to the devices keep their own references
+	defer archive.release()
+
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if s.sendGeneration != generation {
+		s.logChan <- "the clipboard is changed while packing the copied files, ignoring the files"
+		return
+	}
+	s.queueClipboard(cb, archive, isReceivedClipboard)
+}
+
+// queueClipboard queue the clipboard to all peers, the delivery is tracked on the clipboard of the history,
+// the archive of the copied files is sent instead of the clipboard if it's not nil
+func (s *StreamHandler) queueClipboard(cb *clipboard.Clipboard, archive *filesArchive, isReceivedClipboard bool) {
+	clipboardLength := int64(cb.TotalSize())
+	if archive != nil {
+		clipboardLength = archive.size
+	}
 
 	// send data to each devices
-	for name, dv := range s.deviceManager.Devices {
+	for _, dv := range s.deviceManager.ListDevices() {
+		name := dv.AddressInfo.ID.String()
 		if dv.Status == device.StatusPending {
 			// request for public key
 			s.SendSignal(dv, SignalRequestDeviceData)
@@ -85,42 +112,90 @@ func (s *StreamHandler) sendClipboard(clipboardBytes []byte, isImage bool) {
 			continue
 		}
 
-		s.logChan <- fmt.Sprintf("sending data to peer: %s len: %d", name, clipboardLength)
+		// the device without the primary selection would write it to the clipboard
+		if cb.Selection == clipboard.SelectionPrimary && !dv.HasCapability(CapabilityPrimarySelection) {
+			continue
+		}
 
-		clipboardDataBytes, err := s.encodeClipboardData(dv, clipboardData)
-		if err != nil {
-			s.errorChan <- xerror.NewRuntimeError("error encoding data").Wrap(err)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
+		if archive != nil && !dv.HasCapability(CapabilityFiles) {
+			s.errorChan <- xerror.NewRuntimeErrorf("device %s does not support files", name)
 			continue
 		}
 
-		err = s.writeData(dv.Writer, clipboardDataBytes)
-		if err != nil {
-			s.logChan <- fmt.Sprintf("error to send data for peer: %s", name)
-			dv.Status = device.StatusError
-			s.deviceManager.UpdateDevice(dv)
+		// the files are always sent as a transfer from the archive file
+		chunked := archive != nil || clipboardLength > transfer.DefaultChunkSize
+		if chunked && !dv.HasCapability(CapabilityChunkedTransfer) {
+			s.errorChan <- xerror.NewRuntimeErrorf("device %s does not support clipboard size %d", name, clipboardLength)
+			continue
 		}
+
+		// the receipt of the device is acknowledged by the message id, track it before sending
+		messageID := newMessageID()
+		s.clipboardManager.TrackDelivery(cb, dv, messageID)
+		item := &outboxItem{clipboard: cb, messageID: messageID}
+		if archive != nil {
+			archive.acquire()
+			item.archive = archive
+		}
+		s.enqueue(dv, item)
+	}
+}
+
+// sendClipboardData send the clipboard data in one message
+func (s *StreamHandler) sendClipboardData(dv *device.Device, messageID string, clipboardData *protobuf.ClipboardData) error {
+	clipboardData.MessageId = messageID
+	clipboardData.Sequence = s.nextSequence()
+	compression := s.compressionFor(dv, clipboardData.Data)
+	encrypted, err := s.encryptClipboardData(dv, compression, clipboardData)
+	if err != nil {
+		return xerror.NewRuntimeError("error encoding data").Wrap(err)
 	}
+
+	envelope := newEnvelope()
+	envelope.MessageId = messageID
+	envelope.Compression = compression
+	envelope.Payload = &protobuf.Envelope_Clipboard{Clipboard: encrypted}
+	return s.sendEnvelope(dv, envelope)
 }
 
-// sendDeviceData send device data to the giving device
-func (s *StreamHandler) sendDeviceData(dv *device.Device) {
+// sendAck send the receipt of the message to device
+func (s *StreamHandler) sendAck(dv *device.Device, messageID string, status protobuf.AckStatus) {
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_Ack{
+		Ack: &protobuf.Ack{
+			MessageId: messageID,
+			Status:    status,
+		},
+	}
+	err := s.sendEnvelope(dv, envelope)
+	if err != nil {
+		s.errorChan <- xerror.NewRuntimeErrorf("cannot send ack to %s", dv.AddressInfo.ID.Loggable()).Wrap(err)
+	}
+}
+
+// SendDeviceData send device data to the giving device
+func (s *StreamHandler) SendDeviceData(dv *device.Device) {
 	pub, err := s.config.PGPPrivateKey.GetPublicKey()
 	if err != nil {
 		s.errorChan <- xerror.NewFatalError("error to generate pubic key").Wrap(err)
 		return
 	}
-	deviceData, err := s.encodeDeviceData(&protobuf.DeviceData{
-		Name:      s.config.Username,
-		Os:        runtime.GOOS,
-		PublicKey: pub,
-	})
-	if err != nil {
-		s.errorChan <- xerror.NewRuntimeError("cannot encode device data").Wrap(err)
-		return
+	// only the issuer redeems the invite token, it vouches for this device to the other members
+	var inviteToken string
+	joinToken, encoded := s.getJoinToken()
+	if joinToken != nil && joinToken.Issuer == dv.AddressInfo.ID.String() && dv.HasCapability(CapabilityInvite) {
+		inviteToken = encoded
+	}
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_DeviceData{
+		DeviceData: &protobuf.DeviceData{
+			Name:        s.config.Username,
+			Os:          runtime.GOOS,
+			PublicKey:   pub,
+			InviteToken: inviteToken,
+		},
 	}
-	err = s.writeData(dv.Writer, deviceData)
+	err = s.sendEnvelope(dv, envelope)
 	if err != nil {
 		dv.Status = device.StatusError
 		s.deviceManager.UpdateDevice(dv)
@@ -130,12 +205,9 @@ func (s *StreamHandler) sendDeviceData(dv *device.Device) {
 
 // SendSignal send signal to device
 func (s *StreamHandler) SendSignal(dv *device.Device, signal Signal) {
-	signalData, err := s.encodeSignal(signal)
-	if err != nil {
-		s.errorChan <- xerror.NewRuntimeError("cannot encode signal").Wrap(err)
-		return
-	}
-	err = s.writeData(dv.Writer, signalData)
+	envelope := newEnvelope()
+	envelope.Payload = &protobuf.Envelope_Signal{Signal: signal}
+	err := s.sendEnvelope(dv, envelope)
 	if err != nil {
 		dv.Status = device.StatusError
 		s.deviceManager.UpdateDevice(dv)
@@ -143,6 +215,46 @@ func (s *StreamHandler) SendSignal(dv *device.Device, signal Signal) {
 	}
 }
 
+// sendEnvelope encode the envelope and write it to the device, the clipboard payloads are written to the data stream
+// so they don't delay the signals, or to the control stream if the data stream isn't opened
+func (s *StreamHandler) sendEnvelope(dv *device.Device, envelope *protobuf.Envelope) error {
+	frame, err := s.encodeEnvelope(envelope)
+	if err != nil {
+		return err
+	}
+
+	ds := dv.ControlStream
+	if isDataPayload(envelope) {
+		if dataStream := dv.DataStream(); dataStream != nil {
+			ds = dataStream
+		}
+	}
+	ds.WriteMu.Lock()
+	defer ds.WriteMu.Unlock()
+	return s.writeData(ds.Writer, frame)
+}
+
+// TrustDevice trust the device and confirm the pairing to it,
+// the device will be connected after it also confirmed the pairing
+func (s *StreamHandler) TrustDevice(dv *device.Device) error {
+	approvedKey := dv.PendingPublicKey
+	err := dv.Trust()
+	if err != nil {
+		return err
+	}
+	if approvedKey != nil {
+		err := s.auditLogger.Log(audit.EventKeyApproved, dv.AddressInfo.ID.String(), map[string]string{
+			"name":        dv.Name,
+			"fingerprint": fingerprint(approvedKey),
+		})
+		if err != nil {
+			s.errorChan <- xerror.NewRuntimeError("can not write key approved event to audit log").Wrap(err)
+		}
+	}
+	s.SendSignal(dv, SignalPairingConfirmed)
+	return nil
+}
+
 // writeData write data to the writer
 func (s *StreamHandler) writeData(w *bufio.Writer, data []byte) error {
 	_, err := w.Write(data)
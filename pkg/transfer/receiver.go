@@ -0,0 +1,208 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// incoming a transfer being received, the chunks are spooled to a temp file
+type incoming struct {
+	manifest *protobuf.TransferManifest
+	file     *os.File
+	hash     hash.Hash
+	next     uint32
+
+	updatedAt time.Time
+}
+
+// Receiver reassemble the transfers from the peers with bounded memory,
+// each peer has one transfer at a time, a new transfer replaces the unfinished one as the newer clipboard wins,
+// the unfinished transfer is kept after the stream dropped to resume it until it's expired
+type Receiver struct {
+	tempDir string
+	maxSize int
+
+	mu        sync.Mutex
+	transfers map[string]*incoming
+}
+
+// NewReceiver create a new receiver spooling to tempDir, the default temp directory is used if it's empty
+func NewReceiver(tempDir string, maxSize int) *Receiver {
+	return &Receiver{
+		tempDir:   tempDir,
+		maxSize:   maxSize,
+		transfers: make(map[string]*incoming),
+	}
+}
+
+// Start start receiving the transfer of the manifest from the peer
+func (r *Receiver) Start(peerID string, manifest *protobuf.TransferManifest) error {
+	err := r.validate(manifest)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(r.tempDir, "cross-clipboard-transfer-*")
+	if err != nil {
+		return xerror.NewRuntimeError("can not create transfer temp file").Wrap(err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abort(peerID)
+	r.transfers[peerID] = &incoming{
+		manifest:  manifest,
+		file:      file,
+		hash:      sha256.New(),
+		updatedAt: time.Now(),
+	}
+	return nil
+}
+
+// validate check the manifest is consistent and the size is allowed
+func (r *Receiver) validate(manifest *protobuf.TransferManifest) error {
+	if manifest.TransferId == "" {
+		return xerror.NewRuntimeError("transfer id is empty")
+	}
+	if manifest.Size == 0 || manifest.Size > uint64(r.maxSize) {
+		return xerror.NewRuntimeErrorf("transfer size %d is not in range 1 to max size %d", manifest.Size, r.maxSize)
+	}
+	if manifest.ChunkSize == 0 || manifest.ChunkSize > MaxChunkSize {
+		return xerror.NewRuntimeErrorf("transfer chunk size %d is not in range 1 to %d", manifest.ChunkSize, MaxChunkSize)
+	}
+	if manifest.ChunkCount != chunkCount(manifest.Size, manifest.ChunkSize) {
+		return xerror.NewRuntimeErrorf("transfer chunk count %d does not match size %d", manifest.ChunkCount, manifest.Size)
+	}
+	if len(manifest.Sha256) != sha256.Size {
+		return xerror.NewRuntimeError("transfer sha256 is invalid")
+	}
+	return nil
+}
+
+// AddChunk write the next chunk of the peer transfer, returns true when all chunks are received and verified
+func (r *Receiver) AddChunk(peerID string, transferID string, index uint32, data []byte) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	in, ok := r.transfers[peerID]
+	if !ok || in.manifest.TransferId != transferID {
+		return false, xerror.NewRuntimeErrorf("unknown transfer %s", transferID)
+	}
+
+	if index != in.next {
+		r.abort(peerID)
+		return false, xerror.NewRuntimeErrorf("transfer %s got chunk %d, wanted chunk %d", transferID, index, in.next)
+	}
+	if uint64(len(data)) != chunkLength(in.manifest, index) {
+		r.abort(peerID)
+		return false, xerror.NewRuntimeErrorf("transfer %s chunk %d size %d, wanted %d", transferID, index, len(data), chunkLength(in.manifest, index))
+	}
+
+	_, err := in.file.Write(data)
+	if err != nil {
+		r.abort(peerID)
+		return false, xerror.NewRuntimeError("can not write transfer temp file").Wrap(err)
+	}
+	in.hash.Write(data)
+	in.next++
+	in.updatedAt = time.Now()
+
+	if in.next < in.manifest.ChunkCount {
+		return false, nil
+	}
+
+	if !bytes.Equal(in.hash.Sum(nil), in.manifest.Sha256) {
+		r.abort(peerID)
+		return false, xerror.NewRuntimeErrorf("transfer %s sha256 mismatch", transferID)
+	}
+	return true, nil
+}
+
+// Spool the data of a completed transfer in the temp file, closing it removes the file
+type Spool struct {
+	*os.File
+}
+
+// Close close and remove the temp file
+func (sp *Spool) Close() error {
+	sp.File.Close()
+	return os.Remove(sp.Name())
+}
+
+// Finish returns the spooled data and the manifest of the completed peer transfer, the data is read from the temp file
+// so the transfer is not copied to memory, the caller must close the spool
+func (r *Receiver) Finish(peerID string) (*Spool, *protobuf.TransferManifest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	in, ok := r.transfers[peerID]
+	if !ok || in.next < in.manifest.ChunkCount {
+		return nil, nil, xerror.NewRuntimeError("transfer is not completed")
+	}
+
+	_, err := in.file.Seek(0, io.SeekStart)
+	if err != nil {
+		r.abort(peerID)
+		return nil, nil, xerror.NewRuntimeError("can not read transfer temp file").Wrap(err)
+	}
+	// the temp file is owned by the spool now
+	delete(r.transfers, peerID)
+	return &Spool{File: in.file}, in.manifest, nil
+}
+
+// Pending returns the unfinished transfer of the peer and the index of the next chunk to resume from
+func (r *Receiver) Pending(peerID string) (string, uint32, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	in, ok := r.transfers[peerID]
+	if !ok || in.next >= in.manifest.ChunkCount {
+		return "", 0, false
+	}
+	return in.manifest.TransferId, in.next, true
+}
+
+// Expire drop the unfinished transfers not updated since before
+func (r *Receiver) Expire(before time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for peerID, in := range r.transfers {
+		if in.updatedAt.Before(before) {
+			r.abort(peerID)
+		}
+	}
+}
+
+// Abort drop the unfinished transfer of the peer
+func (r *Receiver) Abort(peerID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abort(peerID)
+}
+
+// Close drop all transfers
+func (r *Receiver) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for peerID := range r.transfers {
+		r.abort(peerID)
+	}
+}
+
+func (r *Receiver) abort(peerID string) {
+	in, ok := r.transfers[peerID]
+	if !ok {
+		return
+	}
+	in.file.Close()
+	os.Remove(in.file.Name())
+	delete(r.transfers, peerID)
+}
@@ -0,0 +1,111 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReceiver(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+
+	tests := []struct {
+		name      string
+		maxSize   int
+		chunkSize int
+		order     func(count uint32) []uint32
+		corrupt   bool
+		wantErr   bool
+	}{
+		{
+			name:      "in order",
+			maxSize:   len(data),
+			chunkSize: 3000,
+			order:     inOrder,
+		},
+		{
+			name:      "single chunk",
+			maxSize:   len(data),
+			chunkSize: len(data),
+			order:     inOrder,
+		},
+		{
+			name:      "out of order",
+			maxSize:   len(data),
+			chunkSize: 3000,
+			order:     func(count uint32) []uint32 { return []uint32{1, 0} },
+			wantErr:   true,
+		},
+		{
+			name:      "corrupted chunk",
+			maxSize:   len(data),
+			chunkSize: 3000,
+			order:     inOrder,
+			corrupt:   true,
+			wantErr:   true,
+		},
+		{
+			name:      "larger than max size",
+			maxSize:   len(data) - 1,
+			chunkSize: 3000,
+			order:     inOrder,
+			wantErr:   true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			r := NewReceiver(dir, test.maxSize)
+			manifest := NewManifest("transfer-1", data, false, time.Now(), test.chunkSize)
+
+			err := func() error {
+				err := r.Start("peer", manifest)
+				if err != nil {
+					return err
+				}
+				for _, i := range test.order(manifest.ChunkCount) {
+					chunk := append([]byte{}, Chunk(data, manifest, i)...)
+					if test.corrupt && i == manifest.ChunkCount-1 {
+						chunk[0] ^= 0xFF
+					}
+					done, err := r.AddChunk("peer", manifest.TransferId, i, chunk)
+					if err != nil {
+						return err
+					}
+					if done != (i == manifest.ChunkCount-1) {
+						t.Errorf("got done %v at chunk %d of %d", done, i, manifest.ChunkCount)
+					}
+				}
+				spool, _, err := r.Finish("peer")
+				if err != nil {
+					return err
+				}
+				got, err := io.ReadAll(spool)
+				spool.Close()
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(got, data) {
+					t.Errorf("got %d bytes, wanted the %d bytes sent", len(got), len(data))
+				}
+				return nil
+			}()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("got error %v, wanted error %v", err, test.wantErr)
+			}
+
+			// the temp file is removed after finished or aborted
+			assertNoTempFiles(t, dir)
+		})
+	}
+}
+
+func inOrder(count uint32) []uint32 {
+	order := make([]uint32, count)
+	for i := range order {
+		order[i] = uint32(i)
+	}
+	return order
+}
@@ -0,0 +1,168 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+// outgoing a transfer being sent, the encrypted chunks are cached in a temp file
+type outgoing struct {
+	manifest *protobuf.TransferManifest
+	file     *os.File
+	offsets  []int64 // offset of each chunk in the file, the last one is the file size
+
+	updatedAt time.Time
+}
+
+// Sender cache the encrypted chunks of the transfers to the peers to resume them after the stream dropped,
+// each peer has one transfer at a time, a new transfer replaces the unfinished one
+type Sender struct {
+	tempDir string
+
+	mu        sync.Mutex
+	transfers map[string]*outgoing
+}
+
+// NewSender create a new sender caching to tempDir, the default temp directory is used if it's empty
+func NewSender(tempDir string) *Sender {
+	return &Sender{
+		tempDir:   tempDir,
+		transfers: make(map[string]*outgoing),
+	}
+}
+
+// Cache encrypt each chunk of the data by encrypt and cache it for the transfer to the peer
+func (s *Sender) Cache(peerID string, manifest *protobuf.TransferManifest, data []byte, encrypt func([]byte) ([]byte, error)) error {
+	return s.CacheFrom(peerID, manifest, bytes.NewReader(data), encrypt)
+}
+
+// CacheFrom encrypt each chunk of the data read from r by encrypt and cache it for the transfer to the peer,
+// only one chunk is kept in memory
+func (s *Sender) CacheFrom(peerID string, manifest *protobuf.TransferManifest, r io.Reader, encrypt func([]byte) ([]byte, error)) error {
+	file, err := os.CreateTemp(s.tempDir, "cross-clipboard-send-*")
+	if err != nil {
+		return xerror.NewRuntimeError("can not create transfer temp file").Wrap(err)
+	}
+	out := &outgoing{
+		manifest: manifest,
+		file:     file,
+		offsets:  make([]int64, 0, manifest.ChunkCount+1),
+	}
+
+	chunk := make([]byte, manifest.ChunkSize)
+	offset := int64(0)
+	for i := uint32(0); i < manifest.ChunkCount; i++ {
+		n, err := io.ReadFull(r, chunk[:chunkLength(manifest, i)])
+		if err != nil {
+			out.close()
+			return xerror.NewRuntimeErrorf("can not read transfer chunk %d", i).Wrap(err)
+		}
+		encrypted, err := encrypt(chunk[:n])
+		if err != nil {
+			out.close()
+			return xerror.NewRuntimeErrorf("error to encrypt transfer chunk %d", i).Wrap(err)
+		}
+		_, err = file.Write(encrypted)
+		if err != nil {
+			out.close()
+			return xerror.NewRuntimeError("can not write transfer temp file").Wrap(err)
+		}
+		out.offsets = append(out.offsets, offset)
+		offset += int64(len(encrypted))
+	}
+	out.offsets = append(out.offsets, offset)
+	out.updatedAt = time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remove(peerID)
+	s.transfers[peerID] = out
+	return nil
+}
+
+// Manifest returns the manifest of the cached transfer to the peer
+func (s *Sender) Manifest(peerID string, transferID string) (*protobuf.TransferManifest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, ok := s.transfers[peerID]
+	if !ok || out.manifest.TransferId != transferID {
+		return nil, false
+	}
+	return out.manifest, true
+}
+
+// ReadChunk returns the cached encrypted chunk at index of the transfer to the peer
+func (s *Sender) ReadChunk(peerID string, transferID string, index uint32) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, ok := s.transfers[peerID]
+	if !ok || out.manifest.TransferId != transferID {
+		return nil, xerror.NewRuntimeErrorf("transfer %s is not cached", transferID)
+	}
+	if index >= out.manifest.ChunkCount {
+		return nil, xerror.NewRuntimeErrorf("transfer %s has no chunk %d", transferID, index)
+	}
+
+	chunk := make([]byte, out.offsets[index+1]-out.offsets[index])
+	_, err := out.file.ReadAt(chunk, out.offsets[index])
+	if err != nil && err != io.EOF {
+		return nil, xerror.NewRuntimeError("can not read transfer temp file").Wrap(err)
+	}
+	out.updatedAt = time.Now()
+	return chunk, nil
+}
+
+// Complete remove the transfer to the peer received by the peer
+func (s *Sender) Complete(peerID string, transferID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out, ok := s.transfers[peerID]
+	if !ok || out.manifest.TransferId != transferID {
+		return false
+	}
+	s.remove(peerID)
+	return true
+}
+
+// Expire remove the transfers not read since before
+func (s *Sender) Expire(before time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for peerID, out := range s.transfers {
+		if out.updatedAt.Before(before) {
+			s.remove(peerID)
+		}
+	}
+}
+
+// Close remove all transfers
+func (s *Sender) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for peerID := range s.transfers {
+		s.remove(peerID)
+	}
+}
+
+func (s *Sender) remove(peerID string) {
+	out, ok := s.transfers[peerID]
+	if !ok {
+		return
+	}
+	out.close()
+	delete(s.transfers, peerID)
+}
+
+func (out *outgoing) close() {
+	out.file.Close()
+	os.Remove(out.file.Name())
+}
@@ -0,0 +1,130 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+// xorEncrypt a fake encryption changing the size like pgp does
+func xorEncrypt(data []byte) ([]byte, error) {
+	encrypted := []byte{0x01, 0x02}
+	for _, b := range data {
+		encrypted = append(encrypted, b^0xFF)
+	}
+	return encrypted, nil
+}
+
+func TestSenderResume(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	dir := t.TempDir()
+	s := NewSender(dir)
+	manifest := NewManifest("transfer-1", data, false, time.Now(), 3000)
+
+	if err := s.Cache("peer", manifest, data, xorEncrypt); err != nil {
+		t.Fatal(err)
+	}
+
+	// the receiver got the first chunks before the stream dropped
+	r := NewReceiver(dir, len(data))
+	if err := r.Start("peer", manifest); err != nil {
+		t.Fatal(err)
+	}
+	for i := uint32(0); i < 2; i++ {
+		if _, err := r.AddChunk("peer", manifest.TransferId, i, Chunk(data, manifest, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transferID, next, ok := r.Pending("peer")
+	if !ok || transferID != manifest.TransferId || next != 2 {
+		t.Fatalf("got pending %s %d %v, wanted %s 2 true", transferID, next, ok, manifest.TransferId)
+	}
+
+	// resume from the cached encrypted chunks
+	for i := next; i < manifest.ChunkCount; i++ {
+		encrypted, err := s.ReadChunk("peer", transferID, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := xorEncrypt(Chunk(data, manifest, i))
+		if !bytes.Equal(encrypted, want) {
+			t.Fatalf("got cached chunk %d of %d bytes, wanted %d bytes", i, len(encrypted), len(want))
+		}
+		if _, err := r.AddChunk("peer", transferID, i, Chunk(data, manifest, i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	spool, _, err := r.Finish("peer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(spool)
+	spool.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("got %d bytes, wanted the %d bytes sent", len(got), len(data))
+	}
+	if _, _, ok := r.Pending("peer"); ok {
+		t.Error("got pending transfer after finished")
+	}
+
+	if !s.Complete("peer", transferID) {
+		t.Error("got transfer not cached")
+	}
+	if _, err := s.ReadChunk("peer", transferID, 0); err == nil {
+		t.Error("got chunk of completed transfer")
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestExpire(t *testing.T) {
+	data := bytes.Repeat([]byte("0123456789"), 1000)
+	dir := t.TempDir()
+	manifest := NewManifest("transfer-1", data, false, time.Now(), 3000)
+
+	s := NewSender(dir)
+	if err := s.Cache("peer", manifest, data, xorEncrypt); err != nil {
+		t.Fatal(err)
+	}
+	r := NewReceiver(dir, len(data))
+	if err := r.Start("peer", manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	// not expired yet
+	s.Expire(time.Now().Add(-time.Minute))
+	r.Expire(time.Now().Add(-time.Minute))
+	if _, ok := s.Manifest("peer", manifest.TransferId); !ok {
+		t.Error("got sender transfer expired too early")
+	}
+	if _, _, ok := r.Pending("peer"); !ok {
+		t.Error("got receiver transfer expired too early")
+	}
+
+	s.Expire(time.Now().Add(time.Minute))
+	r.Expire(time.Now().Add(time.Minute))
+	if _, ok := s.Manifest("peer", manifest.TransferId); ok {
+		t.Error("got sender transfer not expired")
+	}
+	if _, _, ok := r.Pending("peer"); ok {
+		t.Error("got receiver transfer not expired")
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("got %d temp files left", len(entries))
+	}
+}
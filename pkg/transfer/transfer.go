@@ -0,0 +1,66 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"time"
+
+	"github.com/yqs112358/cross-clipboard/pkg/protobuf"
+	"github.com/yqs112358/cross-clipboard/pkg/xerror"
+)
+
+const (
+	// DefaultChunkSize the chunk size to send, a clipboard larger than it is sent as a transfer
+	DefaultChunkSize = 256 << 10 // 256 KiB
+	// MaxChunkSize the max chunk size to accept, an encrypted chunk must fit in a frame
+	MaxChunkSize = 512 << 10 // 512 KiB
+)
+
+// NewManifest create the manifest to send the data in chunks of chunkSize
+func NewManifest(transferID string, data []byte, isImage bool, t time.Time, chunkSize int) *protobuf.TransferManifest {
+	// reading from memory never fails
+	manifest, _ := ReadManifest(transferID, bytes.NewReader(data), isImage, t, chunkSize)
+	return manifest
+}
+
+// ReadManifest create the manifest to send the data read from r in chunks of chunkSize, the data is not kept in memory
+func ReadManifest(transferID string, r io.Reader, isImage bool, t time.Time, chunkSize int) (*protobuf.TransferManifest, error) {
+	hash := sha256.New()
+	size, err := io.Copy(hash, r)
+	if err != nil {
+		return nil, xerror.NewRuntimeError("can not read transfer data").Wrap(err)
+	}
+	return &protobuf.TransferManifest{
+		TransferId: transferID,
+		IsImage:    isImage,
+		Time:       t.Unix(),
+		Size:       uint64(size),
+		ChunkSize:  uint32(chunkSize),
+		ChunkCount: chunkCount(uint64(size), uint32(chunkSize)),
+		Sha256:     hash.Sum(nil),
+	}, nil
+}
+
+// Chunk returns the data of the chunk at index
+func Chunk(data []byte, manifest *protobuf.TransferManifest, index uint32) []byte {
+	start := uint64(index) * uint64(manifest.ChunkSize)
+	end := start + uint64(manifest.ChunkSize)
+	if end > uint64(len(data)) {
+		end = uint64(len(data))
+	}
+	return data[start:end]
+}
+
+// chunkCount returns the number of chunks for the size
+func chunkCount(size uint64, chunkSize uint32) uint32 {
+	return uint32((size + uint64(chunkSize) - 1) / uint64(chunkSize))
+}
+
+// chunkLength returns the expected data length of the chunk at index
+func chunkLength(manifest *protobuf.TransferManifest, index uint32) uint64 {
+	if index == manifest.ChunkCount-1 {
+		return manifest.Size - uint64(index)*uint64(manifest.ChunkSize)
+	}
+	return uint64(manifest.ChunkSize)
+}
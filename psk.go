@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/crypto"
+)
+
+// runPSK show the pre-shared key of the group private network,
+// a new key is generated and saved when there is no key or regenerate is true
+func runPSK(cfg *config.Config, regenerate bool) {
+	if cfg.Network.PSK == "" || regenerate {
+		psk, err := crypto.GeneratePSK()
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = cfg.SetPSK(psk)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("generated a new pre-shared key, copy it to network.psk in the config of every device in the group")
+	}
+
+	fmt.Println(cfg.Network.PSK)
+}
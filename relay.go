@@ -0,0 +1,34 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+	"github.com/yqs112358/cross-clipboard/pkg/relay"
+)
+
+// runRelay run this device as a circuit relay for the group until interrupted
+func runRelay(cfg *config.Config) {
+	r, err := relay.NewRelay(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("[*] relay started, PeerID is: %s\n", r.Host.ID())
+	for _, addr := range p2p.FullAddrs(r.Host) {
+		log.Printf("[*] relay address: %s\n", addr)
+	}
+
+	exitSignal := make(chan os.Signal, 1)
+	signal.Notify(exitSignal, os.Interrupt)
+	exit := <-exitSignal
+	log.Printf("got %s signal. stopping relay...\n", exit)
+
+	err = r.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
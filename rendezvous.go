@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/yqs112358/cross-clipboard/pkg/config"
+	"github.com/yqs112358/cross-clipboard/pkg/p2p"
+	"github.com/yqs112358/cross-clipboard/pkg/rendezvous"
+)
+
+// runRendezvous run this device as a rendezvous server for the group until interrupted
+func runRendezvous(cfg *config.Config) {
+	server, err := rendezvous.NewServer(context.Background(), cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("[*] rendezvous server started, PeerID is: %s\n", server.Host.ID())
+	for _, addr := range p2p.FullAddrs(server.Host) {
+		log.Printf("[*] rendezvous address: %s\n", addr)
+	}
+
+	exitSignal := make(chan os.Signal, 1)
+	signal.Notify(exitSignal, os.Interrupt)
+	exit := <-exitSignal
+	log.Printf("got %s signal. stopping rendezvous server...\n", exit)
+
+	err = server.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+}